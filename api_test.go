@@ -0,0 +1,201 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/0x9ef/openai-go/openaitest"
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests of this file run against the fake API server of the openaitest package,
+// which imports this package, so they are in the external test package.
+
+func TestCompletion(t *testing.T) {
+	e, srv := openaitest.NewEngine(t)
+	prompt := `Write a thorough blog post outline with at least 8 sections and a unique structure for a blog post titled “The global recession cases & consequences”.`
+	r, err := e.Completion(context.Background(), &openai.CompletionOptions{
+		Model:  openai.DefaultModel,
+		Prompt: openai.PromptText(prompt),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, openaitest.Text, r.Choices[0].Text)
+	assert.Equal(t, openai.DefaultModel, r.Model)
+	if req := srv.AssertCalled(t, http.MethodPost, "/completions"); req != nil {
+		assert.Equal(t, prompt, req.JSON()["prompt"])
+		assert.Equal(t, "Bearer "+openaitest.APIKey, req.Header.Get("Authorization"))
+	}
+}
+
+func TestEdits(t *testing.T) {
+	e, srv := openaitest.NewEngine(t)
+	r, err := e.Edit(context.Background(), &openai.EditOptions{
+		Model:       "text-davinci-edit-001", // works only with this model
+		Input:       "Write a little bit of Wikipedia. What is that?",
+		Instruction: "Write huge text about Wikipedia in education format.",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, openaitest.Text, r.Choices[0].Text)
+	if req := srv.AssertCalled(t, http.MethodPost, "/edits"); req != nil {
+		assert.Equal(t, "Write huge text about Wikipedia in education format.", req.JSON()["instruction"])
+	}
+}
+
+func TestListModels(t *testing.T) {
+	e, _ := openaitest.NewEngine(t)
+	r, err := e.ListModels(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.NotEmpty(t, r.Data) {
+		assert.Equal(t, openai.ModelGPT4, r.Data[0].ID)
+	}
+}
+
+func TestRetrieveModel(t *testing.T) {
+	e, srv := openaitest.NewEngine(t)
+	r, err := e.RetrieveModel(context.Background(), &openai.RetrieveModelOptions{
+		ID: openai.DefaultModel,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, openai.DefaultModel, r.ID)
+	srv.AssertCalled(t, http.MethodGet, "/models/"+string(openai.DefaultModel))
+}
+
+func TestImageCreate(t *testing.T) {
+	e, srv := openaitest.NewEngine(t)
+	r, err := e.ImageCreate(context.Background(), &openai.ImageCreateOptions{
+		Prompt: "Future human",
+		Size:   openai.SizeSmall,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, r.Data, 1) {
+		assert.Equal(t, "https://images.example.com/0.png", r.Data[0].Url)
+	}
+	if req := srv.AssertCalled(t, http.MethodPost, "/images/generations"); req != nil {
+		assert.Equal(t, "Future human", req.JSON()["prompt"])
+	}
+}
+
+func TestModerate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		category string
+	}{
+		{name: "success:moderate hate", input: "hateful text", category: "hate"},
+		{name: "success:moderate hate threating", input: "threatening text", category: "hate/threatening"},
+		{name: "success:moderate self harm", input: "self-harm text", category: "self-harm"},
+		{name: "success:moderate sexual", input: "sexual text", category: "sexual"},
+		{name: "success:moderate sexual minors", input: "sexual text about minors", category: "sexual/minors"},
+		{name: "success:moderate violence", input: "violent text", category: "violence"},
+		{name: "success:moderate violence graphic", input: "graphic violent text", category: "violence/graphic"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, srv := openaitest.NewEngine(t)
+			srv.Handle(http.MethodPost, "/moderations", openaitest.JSON(http.StatusOK, map[string]interface{}{
+				"id":    "modr-test",
+				"model": "text-moderation-latest",
+				"results": []map[string]interface{}{{
+					"categories":      map[string]bool{tc.category: true},
+					"category_scores": map[string]float64{tc.category: 0.9},
+					"flagged":         true,
+				}},
+			}))
+			r, err := e.Moderate(context.Background(), tc.input)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if req := srv.AssertCalled(t, http.MethodPost, "/moderations"); req != nil {
+				assert.Equal(t, tc.input, req.JSON()["input"])
+			}
+			if !assert.Len(t, r.Results, 1) {
+				return
+			}
+			res := r.Results[0]
+			assert.True(t, res.Flagged)
+			categories := map[string]bool{
+				"hate":             res.Categories.Hate,
+				"hate/threatening": res.Categories.HateThreatening,
+				"self-harm":        res.Categories.SelfHarm,
+				"sexual":           res.Categories.Sexual,
+				"sexual/minors":    res.Categories.SexualMinors,
+				"violence":         res.Categories.Violence,
+				"violence/graphic": res.Categories.ViolenceGraphic,
+			}
+			for category, flagged := range categories {
+				assert.Equal(t, category == tc.category, flagged, category)
+			}
+		})
+	}
+}
+
+func TestAudio(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		format   string
+	}{
+		{
+			name:     "german",
+			filename: "testdata/german.wav",
+			format:   "wav",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, srv := openaitest.NewEngine(t)
+			buffer, err := os.ReadFile(tc.filename)
+			if !assert.NoError(t, err) {
+				return
+			}
+			audioOpts := &openai.AudioOptions{
+				AudioFormat: tc.format,
+				Model:       openai.ModelWhisper,
+				Temperature: 0,
+			}
+			t.Run("transcribe", func(t *testing.T) {
+				audioOpts.File = bytes.NewBuffer(buffer)
+				r, err := e.Transcribe(context.Background(), &openai.TranscribeOptions{AudioOptions: audioOpts})
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, openaitest.Text, r.Text)
+				if req := srv.AssertCalled(t, http.MethodPost, "/audio/transcriptions"); req != nil {
+					name, file, err := req.FormFile("file")
+					assert.NoError(t, err)
+					assert.Equal(t, "file."+tc.format, name)
+					assert.Equal(t, buffer, file)
+					assert.Equal(t, string(openai.ModelWhisper), req.FormValue("model"))
+				}
+			})
+			t.Run("translate", func(t *testing.T) {
+				audioOpts.File = bytes.NewBuffer(buffer)
+				r, err := e.Translate(context.Background(), &openai.TranslateOptions{AudioOptions: audioOpts})
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, openaitest.Text, r.Text)
+				srv.AssertCalled(t, http.MethodPost, "/audio/translations")
+			})
+		})
+	}
+}
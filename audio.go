@@ -3,9 +3,30 @@ package openai
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
+)
+
+// AudioResponseFormat represents the format of the transcription or translation.
+type AudioResponseFormat string
+
+const (
+	AudioResponseFormatJSON        AudioResponseFormat = "json"
+	AudioResponseFormatText        AudioResponseFormat = "text"
+	AudioResponseFormatSRT         AudioResponseFormat = "srt"
+	AudioResponseFormatVerboseJSON AudioResponseFormat = "verbose_json"
+	AudioResponseFormatVTT         AudioResponseFormat = "vtt"
+)
+
+// TimestampGranularity represents the granularity of timestamps in the verbose transcription.
+type TimestampGranularity string
+
+const (
+	TimestampGranularityWord    TimestampGranularity = "word"
+	TimestampGranularitySegment TimestampGranularity = "segment"
 )
 
 type AudioOptions struct {
@@ -25,6 +46,56 @@ type AudioOptions struct {
 	// If set to 0, the model will use log probability to automatically increase
 	// the temperature until certain thresholds are hit.
 	Temperature float32
+	// The format of the response, one of json, text, srt, verbose_json, or vtt.
+	// For verbose_json, the response has Verbose field set. For text, srt and vtt, Text is the raw response.
+	//
+	// Default: json
+	ResponseFormat AudioResponseFormat `binding:"omitempty,oneof=json text srt verbose_json vtt"`
+}
+
+// VerboseTranscription is the transcription or translation in verbose_json format.
+type VerboseTranscription struct {
+	// The task, transcribe or translate.
+	Task string `json:"task"`
+	// The language of the input audio.
+	Language string `json:"language"`
+	// The duration of the input audio, in seconds.
+	Duration float64 `json:"duration"`
+	// The transcribed text.
+	Text string `json:"text"`
+	// Segments of the transcribed text, set if segment timestamps are requested (default).
+	Segments []TranscriptionSegment `json:"segments"`
+	// Words of the transcribed text, set if word timestamps are requested.
+	Words []TranscriptionWord `json:"words"`
+}
+
+// TranscriptionSegment is the segment of the transcribed text with its timestamps.
+type TranscriptionSegment struct {
+	Id   int `json:"id"`
+	Seek int `json:"seek"`
+	// Start and end time of the segment, in seconds.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Text content of the segment.
+	Text string `json:"text"`
+	// Token IDs of the text content.
+	Tokens      []int   `json:"tokens"`
+	Temperature float64 `json:"temperature"`
+	// Average log probability of the segment. If the value is lower than -1, consider the logprobs failed.
+	AvgLogprob float64 `json:"avg_logprob"`
+	// Compression ratio of the segment. If the value is greater than 2.4, consider the compression failed.
+	CompressionRatio float64 `json:"compression_ratio"`
+	// Probability of no speech in the segment. If the value is higher than 1.0
+	// and AvgLogprob is below -1, consider this segment silent.
+	NoSpeechProb float64 `json:"no_speech_prob"`
+}
+
+// TranscriptionWord is the word of the transcribed text with its timestamps.
+type TranscriptionWord struct {
+	Word string `json:"word"`
+	// Start and end time of the word, in seconds.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
 }
 
 type TranscribeOptions struct {
@@ -32,10 +103,16 @@ type TranscribeOptions struct {
 	// The language of the input audio. Supplying the input language in ISO-639-1
 	// format will improve accuracy and latency.
 	Language string
+	// The timestamp granularities to populate, word and/or segment.
+	// Requires verbose_json response format. Word timestamps incur additional latency.
+	TimestampGranularities []TimestampGranularity `binding:"omitempty,dive,oneof=word segment"`
 }
 
 type TranscribeResponse struct {
+	// The transcribed text. For text, srt and vtt formats, it's the raw response, e.g. subtitles.
 	Text string `json:"text"`
+	// The verbose transcription, set for verbose_json format.
+	Verbose *VerboseTranscription `json:"-"`
 }
 
 // Transcribe audio into the input language.
@@ -45,12 +122,21 @@ func (e *Engine) Transcribe(ctx context.Context, opts *TranscribeOptions) (*Tran
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	url := e.apiBaseURL + "/audio/transcriptions"
+	if err := checkModel(opts.Model, EndpointAudioTranscriptions); err != nil {
+		return nil, err
+	}
+	if len(opts.TimestampGranularities) != 0 && opts.ResponseFormat != AudioResponseFormatVerboseJSON {
+		return nil, errors.New("timestamp granularities require verbose_json response format")
+	}
+	uri, err := e.deploymentURL("/audio/transcriptions", opts.Model)
+	if err != nil {
+		return nil, err
+	}
 	body, contentType, err := newTranscribeBody(opts)
 	if err != nil {
 		return nil, err
 	}
-	req, err := e.newReq(ctx, "POST", url, contentType, body)
+	req, err := e.newReq(ctx, "POST", uri, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -58,11 +144,11 @@ func (e *Engine) Transcribe(ctx context.Context, opts *TranscribeOptions) (*Tran
 	if err != nil {
 		return nil, err
 	}
-	var jsonResp TranscribeResponse
-	if err := unmarshal(resp, &jsonResp); err != nil {
+	text, verbose, err := decodeAudioResponse(resp, opts.ResponseFormat)
+	if err != nil {
 		return nil, err
 	}
-	return &jsonResp, nil
+	return &TranscribeResponse{Text: text, Verbose: verbose}, nil
 }
 
 func newTranscribeBody(opts *TranscribeOptions) (io.Reader, string, error) {
@@ -76,6 +162,11 @@ func newTranscribeBody(opts *TranscribeOptions) (io.Reader, string, error) {
 			return nil, "", fmt.Errorf("write language: %w", err)
 		}
 	}
+	for _, g := range opts.TimestampGranularities {
+		if err := writer.WriteField("timestamp_granularities[]", string(g)); err != nil {
+			return nil, "", fmt.Errorf("write timestamp granularities: %w", err)
+		}
+	}
 	if err := writer.Close(); err != nil {
 		return nil, "", fmt.Errorf("close writer: %w", err)
 	}
@@ -87,7 +178,10 @@ type TranslateOptions struct {
 }
 
 type TranslateResponse struct {
+	// The translated text. For text, srt and vtt formats, it's the raw response, e.g. subtitles.
 	Text string `json:"text"`
+	// The verbose translation, set for verbose_json format.
+	Verbose *VerboseTranscription `json:"-"`
 }
 
 // Translate audio into English.
@@ -97,12 +191,18 @@ func (e *Engine) Translate(ctx context.Context, opts *TranslateOptions) (*Transl
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	url := e.apiBaseURL + "/audio/translations"
+	if err := checkModel(opts.Model, EndpointAudioTranslations); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/audio/translations", opts.Model)
+	if err != nil {
+		return nil, err
+	}
 	body, contentType, err := newTranslateBody(opts)
 	if err != nil {
 		return nil, err
 	}
-	req, err := e.newReq(ctx, "POST", url, contentType, body)
+	req, err := e.newReq(ctx, "POST", uri, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -110,11 +210,11 @@ func (e *Engine) Translate(ctx context.Context, opts *TranslateOptions) (*Transl
 	if err != nil {
 		return nil, err
 	}
-	var jsonResp TranslateResponse
-	if err := unmarshal(resp, &jsonResp); err != nil {
+	text, verbose, err := decodeAudioResponse(resp, opts.ResponseFormat)
+	if err != nil {
 		return nil, err
 	}
-	return &jsonResp, nil
+	return &TranslateResponse{Text: text, Verbose: verbose}, nil
 }
 
 func newTranslateBody(opts *TranslateOptions) (io.Reader, string, error) {
@@ -134,8 +234,11 @@ func newAudioMultipartWriter(body *bytes.Buffer, opts *AudioOptions) (*multipart
 	if err := writer.WriteField("model", string(opts.Model)); err != nil {
 		return nil, fmt.Errorf("write model: %w", err)
 	}
-	// TODO: support additional response data via verbose_json? what about other formats (vtt, srt)?g
-	if err := writer.WriteField("response_format", "json"); err != nil {
+	format := opts.ResponseFormat
+	if format == "" {
+		format = AudioResponseFormatJSON
+	}
+	if err := writer.WriteField("response_format", string(format)); err != nil {
 		return nil, fmt.Errorf("write response format: %w", err)
 	}
 	file, err := writer.CreateFormFile("file", "file."+opts.AudioFormat)
@@ -157,3 +260,29 @@ func newAudioMultipartWriter(body *bytes.Buffer, opts *AudioOptions) (*multipart
 	}
 	return writer, nil
 }
+
+// decodeAudioResponse returns the text of the response in the format, and the verbose transcription for verbose_json.
+func decodeAudioResponse(resp *http.Response, format AudioResponseFormat) (string, *VerboseTranscription, error) {
+	switch format {
+	case AudioResponseFormatText, AudioResponseFormatSRT, AudioResponseFormatVTT:
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", nil, err
+		}
+		return string(b), nil, nil
+	case AudioResponseFormatVerboseJSON:
+		var v VerboseTranscription
+		if err := unmarshal(resp, &v); err != nil {
+			return "", nil, err
+		}
+		return v.Text, &v, nil
+	}
+	var v struct {
+		Text string `json:"text"`
+	}
+	if err := unmarshal(resp, &v); err != nil {
+		return "", nil, err
+	}
+	return v.Text, nil, nil
+}
@@ -1,56 +1,72 @@
 package openai
 
 import (
-	"bytes"
 	"context"
-	"io"
-	"os"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestAudio(t *testing.T) {
-	testCases := []struct {
-		name              string
-		filename          string
-		wantTranscription string
-		wantTranslation   string
-	}{
-		{
-			name:              "german",
-			filename:          "testdata/german.wav",
-			wantTranscription: "Ich will das eben wegbringen und dann mit Karl was trinken gehen.",
-			wantTranslation:   "I just want to take this away and go out for a drink with Karl.",
-		},
+func TestAudioResponseFormat(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		form = r.MultipartForm.Value
+		switch r.FormValue("response_format") {
+		case "srt":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("1\n00:00:00,000 --> 00:00:01,500\nHallo\n\n"))
+		case "verbose_json":
+			w.Write([]byte(`{"task":"transcribe","language":"german","duration":1.5,"text":"Hallo",
+				"segments":[{"id":0,"seek":0,"start":0,"end":1.5,"text":"Hallo","tokens":[1],"temperature":0,
+					"avg_logprob":-0.25,"compression_ratio":0.8,"no_speech_prob":0.02}],
+				"words":[{"word":"Hallo","start":0.1,"end":0.9}]}`))
+		default:
+			w.Write([]byte(`{"text":"Hallo"}`))
+		}
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+	audioOpts := func(format AudioResponseFormat) *AudioOptions {
+		return &AudioOptions{File: strings.NewReader("RIFF"), AudioFormat: "wav", Model: ModelWhisper, ResponseFormat: format}
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			e := New(os.Getenv("OPENAI_KEY"))
-			file, err := os.Open(tc.filename)
-			assert.NoError(t, err)
-			defer file.Close()
-			buffer, err := io.ReadAll(file)
-			assert.NoError(t, err)
-			audioOpts := &AudioOptions{
-				AudioFormat: strings.Split(tc.filename, ".")[1],
-				Model:       ModelWhisper,
-				Temperature: 0,
-			}
-			t.Run("transcribe", func(t *testing.T) {
-				audioOpts.File = bytes.NewBuffer(buffer)
-				r, err := e.Transcribe(context.Background(), &TranscribeOptions{AudioOptions: audioOpts})
-				assert.NoError(t, err)
-				assert.Equal(t, tc.wantTranscription, r.Text)
-			})
-			t.Run("translate", func(t *testing.T) {
-				audioOpts.File = bytes.NewBuffer(buffer)
-				r, err := e.Translate(context.Background(), &TranslateOptions{AudioOptions: audioOpts})
-				assert.NoError(t, err)
-				assert.Equal(t, tc.wantTranslation, r.Text)
-			})
-		})
+	r, err := e.Transcribe(ctx, &TranscribeOptions{AudioOptions: audioOpts("")})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hallo", r.Text)
+	assert.Nil(t, r.Verbose)
+	assert.Equal(t, []string{"json"}, form["response_format"])
+
+	r, err = e.Transcribe(ctx, &TranscribeOptions{
+		AudioOptions:           audioOpts(AudioResponseFormatVerboseJSON),
+		TimestampGranularities: []TimestampGranularity{TimestampGranularityWord, TimestampGranularitySegment},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"word", "segment"}, form["timestamp_granularities[]"])
+	assert.Equal(t, "Hallo", r.Text)
+	if assert.NotNil(t, r.Verbose) {
+		assert.Equal(t, "german", r.Verbose.Language)
+		assert.Equal(t, 1.5, r.Verbose.Duration)
+		assert.Equal(t, -0.25, r.Verbose.Segments[0].AvgLogprob)
+		assert.Equal(t, 0.8, r.Verbose.Segments[0].CompressionRatio)
+		assert.Equal(t, 0.02, r.Verbose.Segments[0].NoSpeechProb)
+		assert.Equal(t, TranscriptionWord{Word: "Hallo", Start: 0.1, End: 0.9}, r.Verbose.Words[0])
 	}
+
+	tr, err := e.Translate(ctx, &TranslateOptions{AudioOptions: audioOpts(AudioResponseFormatSRT)})
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,500\nHallo\n\n", tr.Text)
+	assert.Nil(t, tr.Verbose)
+
+	_, err = e.Transcribe(ctx, &TranscribeOptions{
+		AudioOptions:           audioOpts(AudioResponseFormatJSON),
+		TimestampGranularities: []TimestampGranularity{TimestampGranularityWord},
+	})
+	assert.Error(t, err, "timestamp granularities require verbose_json")
+	_, err = e.Transcribe(ctx, &TranscribeOptions{AudioOptions: audioOpts("xml")})
+	assert.Error(t, err)
 }
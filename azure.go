@@ -0,0 +1,105 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used if AzureConfig.APIVersion is empty.
+const DefaultAzureAPIVersion = "2024-02-01"
+
+// AzureConfig configures the engine to use Azure OpenAI Service.
+type AzureConfig struct {
+	// Endpoint of the Azure OpenAI resource, e.g. https://my-resource.openai.azure.com.
+	Endpoint string
+	// Value of the mandatory api-version query parameter.
+	//
+	// Default: DefaultAzureAPIVersion
+	APIVersion string
+	// Deployments maps models to names of their deployments.
+	// If the model is not in the map, its name is used as the deployment name.
+	Deployments map[Model]string
+	// Deployment used by requests without model, e.g. image generation.
+	DefaultDeployment string
+}
+
+// ErrNoDeployment is returned when the request to Azure OpenAI has neither model nor default deployment.
+var ErrNoDeployment = errors.New("azure: no deployment for the request")
+
+// WithAzure configures the engine to send requests to Azure OpenAI Service.
+// Requests of models are sent to their deployments at
+// {endpoint}/openai/deployments/{deployment}/..., other requests (e.g. files and fine-tuning) to {endpoint}/openai/...
+// The API key is sent in the api-key header instead of Authorization.
+func WithAzure(cfg AzureConfig) Option {
+	return func(e *Engine) {
+		cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+		if cfg.APIVersion == "" {
+			cfg.APIVersion = DefaultAzureAPIVersion
+		}
+		e.azure = &cfg
+		e.apiBaseURL = cfg.Endpoint + "/openai"
+	}
+}
+
+// deployment returns the name of the deployment serving the model.
+func (c *AzureConfig) deployment(model Model) string {
+	if d, ok := c.Deployments[model]; ok {
+		return d
+	}
+	if model != "" {
+		return string(model)
+	}
+	return c.DefaultDeployment
+}
+
+// apiURL returns the URL of the API endpoint which doesn't belong to a model, e.g. /files.
+func (e *Engine) apiURL(path string) string {
+	if e.azure == nil {
+		return e.apiBaseURL + path
+	}
+	return e.apiBaseURL + path + "?api-version=" + url.QueryEscape(e.azure.APIVersion)
+}
+
+// deploymentURL returns the URL of the API endpoint serving the model, e.g. /chat/completions.
+// On Azure, it's the endpoint of the model deployment.
+func (e *Engine) deploymentURL(path string, model Model) (string, error) {
+	if e.azure == nil {
+		return e.apiBaseURL + path, nil
+	}
+	deployment := e.azure.deployment(model)
+	if deployment == "" {
+		return "", ErrNoDeployment
+	}
+	return e.apiURL("/deployments/" + url.PathEscape(deployment) + path), nil
+}
+
+// endpointPath returns the API endpoint of the request URL, e.g. /chat/completions,
+// without the deployment prefix.
+func (e *Engine) endpointPath(u *url.URL) string {
+	endpoint := strings.TrimPrefix(u.String(), e.apiBaseURL)
+	if i := strings.IndexByte(endpoint, '?'); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	if e.azure != nil && strings.HasPrefix(endpoint, "/deployments/") {
+		rest := strings.TrimPrefix(endpoint, "/deployments/")
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			endpoint = rest[i:]
+		}
+	}
+	return endpoint
+}
+
+// withQuery appends the query to the URL which may already have query parameters.
+func withQuery(uri string, query url.Values) string {
+	if len(query) == 0 {
+		return uri
+	}
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query.Encode()
+	}
+	return uri + "?" + query.Encode()
+}
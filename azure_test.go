@@ -0,0 +1,179 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAzure(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.Equal(t, "2024-06-01", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "azure-key", r.Header.Get("Api-Key"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+			w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"Hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+		case strings.HasSuffix(r.URL.Path, "/embeddings"):
+			w.Write([]byte(`{"data":[{"embedding":[0.1]}]}`))
+		case r.URL.Path == "/openai/files":
+			assert.Equal(t, "fine-tune", r.URL.Query().Get("purpose"))
+			w.Write([]byte(`{"object":"list","data":[]}`))
+		case strings.HasPrefix(r.URL.Path, "/openai/fine_tuning/jobs"):
+			assert.Equal(t, "5", r.URL.Query().Get("limit"))
+			w.Write([]byte(`{"object":"list","data":[]}`))
+		default:
+			w.Write([]byte(`{"created":1,"data":[{"url":"https://example.com/1.png"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("azure-key", WithAzure(AzureConfig{
+		Endpoint:          srv.URL + "/",
+		APIVersion:        "2024-06-01",
+		Deployments:       map[Model]string{ModelGPT3Dot5Turbo: "chat-prod"},
+		DefaultDeployment: "dalle",
+	}))
+	ctx := context.Background()
+	chat, err := e.ChatCompletion(ctx, &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi", chat.Choices[0].Message.Content)
+
+	_, err = e.Embeddings(ctx, &EmbeddingOptions{Model: ModelTextEmbeddingAda002, Input: EmbeddingText("Hello")})
+	assert.NoError(t, err)
+	_, err = e.ImageCreate(ctx, &ImageCreateOptions{Prompt: "cat", Size: SizeSmall})
+	assert.NoError(t, err)
+	_, err = e.ListFiles(ctx, &ListFilesOptions{Purpose: FilePurposeFineTune})
+	assert.NoError(t, err)
+	_, err = e.ListFineTuningJobs(ctx, &ListOptions{Limit: 5})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/openai/deployments/chat-prod/chat/completions",
+		"/openai/deployments/text-embedding-ada-002/embeddings",
+		"/openai/deployments/dalle/images/generations",
+		"/openai/files",
+		"/openai/fine_tuning/jobs",
+	}, paths)
+
+	// Deployment prefix is not part of the endpoint
+	stats := e.Stats()
+	assert.Equal(t, int64(1), stats.Endpoints["/chat/completions"])
+	assert.Equal(t, int64(1), stats.Endpoints["/images/generations"])
+
+	// Image generation has no model, so the default deployment is required
+	e = NewWithOptions("azure-key", WithAzure(AzureConfig{Endpoint: srv.URL}))
+	_, err = e.ImageCreate(ctx, &ImageCreateOptions{Prompt: "cat", Size: SizeSmall})
+	assert.ErrorIs(t, err, ErrNoDeployment)
+	assert.Equal(t, DefaultAzureAPIVersion, e.azure.APIVersion)
+}
+
+func TestAzureAPIError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantErr    *APIError
+		wantIs     error
+	}{
+		{
+			name:       "error:deployment not found",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusNotFound,
+				Code:       "DeploymentNotFound",
+				Message:    "The API deployment for this resource does not exist.",
+				RequestID:  "apim-123",
+			},
+			wantIs: ErrModelNotFound,
+		},
+		{
+			name:       "error:invalid key",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":{"code":"401","message":"Access denied due to invalid subscription key or wrong API endpoint."}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusUnauthorized,
+				Code:       "401",
+				Message:    "Access denied due to invalid subscription key or wrong API endpoint.",
+				RequestID:  "apim-123",
+			},
+			wantIs: ErrInvalidAPIKey,
+		},
+		{
+			name:       "error:gateway",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"statusCode":401,"message":"Unauthorized. Access token is missing, invalid, audience is incorrect, or have expired."}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusUnauthorized,
+				Message:    "Unauthorized. Access token is missing, invalid, audience is incorrect, or have expired.",
+				RequestID:  "apim-123",
+			},
+			wantIs: ErrInvalidAPIKey,
+		},
+		{
+			name:       "error:rate limited",
+			statusCode: http.StatusTooManyRequests,
+			body:       `{"error":{"code":"429","message":"Requests to the ChatCompletions_Create Operation have exceeded call rate limit."}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Code:       "429",
+				Message:    "Requests to the ChatCompletions_Create Operation have exceeded call rate limit.",
+				RequestID:  "apim-123",
+			},
+			wantIs: ErrRateLimited,
+		},
+		{
+			name:       "error:content filter",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"message":"The response was filtered due to the prompt triggering Azure OpenAI's content management policy.","type":null,"param":"prompt","code":"content_filter","status":400,` +
+				`"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"hate":{"filtered":false,"severity":"safe"},"violence":{"filtered":true,"severity":"high"},"jailbreak":{"filtered":false,"detected":false}}}}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusBadRequest,
+				Code:       "content_filter",
+				Param:      "prompt",
+				Message:    "The response was filtered due to the prompt triggering Azure OpenAI's content management policy.",
+				InnerCode:  "ResponsibleAIPolicyViolation",
+				ContentFilterResults: map[string]ContentFilterResult{
+					"hate":      {Severity: "safe"},
+					"violence":  {Filtered: true, Severity: "high"},
+					"jailbreak": {},
+				},
+				RequestID: "apim-123",
+			},
+			wantIs: ErrContentFiltered,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Apim-Request-Id", "apim-123")
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			e := NewWithOptions("azure-key", WithAzure(AzureConfig{Endpoint: srv.URL}), WithRetryPolicy(RetryPolicy{}))
+			_, err := e.ListModels(context.Background())
+			var apiErr *APIError
+			if assert.True(t, errors.As(err, &apiErr)) {
+				apiErr.Header = nil
+				assert.Equal(t, tc.wantErr, apiErr)
+			}
+			assert.ErrorIs(t, err, tc.wantIs)
+		})
+	}
+}
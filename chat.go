@@ -0,0 +1,148 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"net/http"
+)
+
+// Role represents the author of the chat message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// FinishReason represents the reason the model stopped generating tokens.
+type FinishReason string
+
+const (
+	// FinishReasonStop the model hit a natural stop point or a provided stop sequence.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength the maximum number of tokens specified in the request was reached.
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonToolCalls the model called a tool.
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonContentFilter content was omitted due to a flag from content filters.
+	FinishReasonContentFilter FinishReason = "content_filter"
+)
+
+type ChatMessage struct {
+	// The role of the author of this message.
+	// Must be one of system, user, assistant or tool.
+	Role Role `json:"role" binding:"required,oneof=system user assistant tool"`
+	// The contents of the message.
+	Content string `json:"content"`
+	// An optional name for the participant.
+	// Provides the model information to differentiate between participants of the same role.
+	Name string `json:"name,omitempty" binding:"omitempty,max=64"`
+	// The tool calls generated by the model, such as function calls.
+	// Only present in assistant messages.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Tool call that this message is responding to.
+	// Required for messages with tool role.
+	ToolCallId string `json:"tool_call_id,omitempty" binding:"required_if=Role tool"`
+}
+
+type ToolCall struct {
+	// The ID of the tool call.
+	Id string `json:"id"`
+	// The type of the tool. Currently, only function is supported.
+	Type string `json:"type"`
+	// The function that the model called.
+	Function struct {
+		// The name of the function to call.
+		Name string `json:"name"`
+		// The arguments to call the function with, as generated by the model in JSON format.
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatCompletionOptions struct {
+	// ID of the model to use.
+	Model Model `json:"model" binding:"required"`
+	// A list of messages comprising the conversation so far.
+	Messages []ChatMessage `json:"messages" binding:"required,min=1,dive"`
+	// The maximum number of tokens that can be generated in the chat completion.
+	MaxTokens int `json:"max_tokens,omitempty" binding:"omitempty,min=1"`
+	// What sampling temperature to use, between 0 and 2. Higher values like 0.8 will make the output more random,
+	// while lower values like 0.2 will make it more focused and deterministic.
+	Temperature float32 `json:"temperature,omitempty" binding:"omitempty,min=0,max=2"`
+	// An alternative to sampling with temperature, called nucleus sampling, where the model considers
+	// the results of the tokens with top_p probability mass.
+	// So 0.1 means only the tokens comprising the top 10% probability mass are considered.
+	TopP float32 `json:"top_p,omitempty" binding:"omitempty,min=0,max=1"`
+	// How many chat completion choices to generate for each input message.
+	N int `json:"n,omitempty" binding:"omitempty,min=1"`
+	// Up to 4 sequences where the API will stop generating further tokens.
+	Stop []string `json:"stop,omitempty" binding:"omitempty,max=4"`
+	// Number between -2.0 and 2.0. Positive values penalize new tokens based on whether they appear
+	// in the text so far, increasing the model's likelihood to talk about new topics.
+	PresencePenalty float32 `json:"presence_penalty,omitempty" binding:"omitempty,min=-2,max=2"`
+	// Number between -2.0 and 2.0. Positive values penalize new tokens based on their existing frequency
+	// in the text so far, decreasing the model's likelihood to repeat the same line verbatim.
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty" binding:"omitempty,min=-2,max=2"`
+	// Modify the likelihood of specified tokens appearing in the completion.
+	// Maps tokens (specified by their token ID in the tokenizer) to an associated bias value from -100 to 100.
+	LogitBias map[int]int `json:"logit_bias,omitempty" binding:"omitempty,dive,min=-100,max=100"`
+	// A unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
+	User string `json:"user,omitempty"`
+	// If specified, the system will make a best effort to sample deterministically, such that
+	// repeated requests with the same seed and parameters should return the same result.
+	Seed int `json:"seed,omitempty"`
+}
+
+type ChatCompletionResponse struct {
+	Id                string                 `json:"id"`
+	Object            string                 `json:"object"`
+	Created           int                    `json:"created"`
+	Model             Model                  `json:"model"`
+	SystemFingerprint string                 `json:"system_fingerprint"`
+	Choices           []ChatCompletionChoice `json:"choices"`
+	Usage             Usage                  `json:"usage"`
+}
+
+type ChatCompletionChoice struct {
+	Index        int          `json:"index"`
+	Message      ChatMessage  `json:"message"`
+	FinishReason FinishReason `json:"finish_reason"`
+}
+
+// ChatCompletion given a list of messages comprising a conversation, the model will return a response.
+//
+// Docs: https://platform.openai.com/docs/api-reference/chat/create
+func (e *Engine) ChatCompletion(ctx context.Context, opts *ChatCompletionOptions) (*ChatCompletionResponse, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	if err := checkModel(opts.Model, EndpointChatCompletions); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/chat/completions", opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	r, err := marshalJson(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp ChatCompletionResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	e.stats.recordUsage(jsonResp.Usage)
+	return &jsonResp, nil
+}
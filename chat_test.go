@@ -0,0 +1,97 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatCompletion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test", r.Header.Get("Authorization"))
+		var opts ChatCompletionOptions
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		assert.Equal(t, ModelGPT3Dot5Turbo, opts.Model)
+		assert.Len(t, opts.Messages, 2)
+		assert.Equal(t, RoleSystem, opts.Messages[0].Role)
+		assert.Equal(t, 5, opts.LogitBias[50256])
+		w.Write([]byte(`{
+			"id": "chatcmpl-123",
+			"object": "chat.completion",
+			"created": 1677652288,
+			"model": "gpt-3.5-turbo-0613",
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "Hello there, how may I assist you today?"},
+				"finish_reason": "stop"
+			}],
+			"usage": {"prompt_tokens": 9, "completion_tokens": 12, "total_tokens": 21}
+		}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	r, err := e.ChatCompletion(context.Background(), &ChatCompletionOptions{
+		Model: ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{
+			{Role: RoleSystem, Content: "You are a helpful assistant."},
+			{Role: RoleUser, Content: "Hello!"},
+		},
+		LogitBias: map[int]int{50256: 5},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, RoleAssistant, r.Choices[0].Message.Role)
+	assert.Equal(t, "Hello there, how may I assist you today?", r.Choices[0].Message.Content)
+	assert.Equal(t, FinishReasonStop, r.Choices[0].FinishReason)
+	assert.Equal(t, 21, r.Usage.TotalTokens)
+}
+
+func TestChatCompletionValidation(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts *ChatCompletionOptions
+	}{
+		{
+			name: "error:empty messages",
+			opts: &ChatCompletionOptions{Model: ModelGPT3Dot5Turbo},
+		},
+		{
+			name: "error:unknown role",
+			opts: &ChatCompletionOptions{
+				Model:    ModelGPT3Dot5Turbo,
+				Messages: []ChatMessage{{Role: "robot", Content: "beep"}},
+			},
+		},
+		{
+			name: "error:tool message without tool call id",
+			opts: &ChatCompletionOptions{
+				Model:    ModelGPT3Dot5Turbo,
+				Messages: []ChatMessage{{Role: RoleTool, Content: "42"}},
+			},
+		},
+		{
+			name: "error:temperature out of range",
+			opts: &ChatCompletionOptions{
+				Model:       ModelGPT3Dot5Turbo,
+				Messages:    []ChatMessage{{Role: RoleUser, Content: "Hello!"}},
+				Temperature: 2.5,
+			},
+		},
+	}
+
+	e := New("test")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := e.ChatCompletion(context.Background(), tc.opts)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -5,43 +5,239 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
 )
 
+type promptKind int
+
+const (
+	promptKindTexts promptKind = iota
+	promptKindText
+	promptKindTokens
+	promptKindTokenArrays
+)
+
+// Prompt is the prompt to generate completions for, encoded as a string, an array of strings,
+// an array of tokens or an array of token arrays.
+// Use PromptText, PromptTexts, PromptTokens or PromptTokenArrays to create it.
+//
+// Pre-tokenized prompts are not tokenized by the API again, which saves time on very long inputs.
+type Prompt struct {
+	kind        promptKind
+	texts       []string
+	tokens      []int
+	tokenArrays [][]int
+}
+
+// PromptText creates the prompt from a single text.
+func PromptText(text string) Prompt {
+	return Prompt{kind: promptKindText, texts: []string{text}}
+}
+
+// PromptTexts creates the prompt from multiple texts, a completion is generated for each of them.
+// Use PromptTexts(texts...) in place of []string prompts of previous versions.
+func PromptTexts(texts ...string) Prompt {
+	return Prompt{kind: promptKindTexts, texts: texts}
+}
+
+// PromptTokens creates the prompt from tokens of a single text.
+func PromptTokens(tokens ...int) Prompt {
+	return Prompt{kind: promptKindTokens, tokens: tokens}
+}
+
+// PromptTokenArrays creates the prompt from multiple token arrays, a completion is generated for each of them.
+func PromptTokenArrays(tokens ...[]int) Prompt {
+	return Prompt{kind: promptKindTokenArrays, tokenArrays: tokens}
+}
+
+// Len returns the number of prompts.
+func (p Prompt) Len() int {
+	switch p.kind {
+	case promptKindTokens:
+		if len(p.tokens) == 0 {
+			return 0
+		}
+		return 1
+	case promptKindTokenArrays:
+		return len(p.tokenArrays)
+	}
+	return len(p.texts)
+}
+
+// Texts returns texts of the prompt, nil if the prompt is made of tokens.
+func (p Prompt) Texts() []string {
+	return p.texts
+}
+
+// Tokens returns token arrays of the prompt, nil if the prompt is made of texts.
+func (p Prompt) Tokens() [][]int {
+	switch p.kind {
+	case promptKindTokens:
+		return [][]int{p.tokens}
+	case promptKindTokenArrays:
+		return p.tokenArrays
+	}
+	return nil
+}
+
+func (p Prompt) MarshalJSON() ([]byte, error) {
+	switch p.kind {
+	case promptKindText:
+		return json.Marshal(p.texts[0])
+	case promptKindTokens:
+		return json.Marshal(p.tokens)
+	case promptKindTokenArrays:
+		return json.Marshal(p.tokenArrays)
+	}
+	return json.Marshal(p.texts)
+}
+
+// UnmarshalJSON decodes the prompt of any form, e.g. from recorded requests.
+func (p *Prompt) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*p = PromptText(text)
+		return nil
+	}
+	var texts []string
+	if err := json.Unmarshal(data, &texts); err == nil {
+		*p = PromptTexts(texts...)
+		return nil
+	}
+	var tokens []int
+	if err := json.Unmarshal(data, &tokens); err == nil {
+		*p = PromptTokens(tokens...)
+		return nil
+	}
+	var tokenArrays [][]int
+	if err := json.Unmarshal(data, &tokenArrays); err == nil {
+		*p = PromptTokenArrays(tokenArrays...)
+		return nil
+	}
+	return fmt.Errorf("invalid prompt: %s", data)
+}
+
+// promptValue lets validator check Prompt by the number of prompts.
+func promptValue(v reflect.Value) interface{} {
+	if p, ok := v.Interface().(Prompt); ok {
+		return p.Len()
+	}
+	return nil
+}
+
 type CompletionOptions struct {
 	// ID of the model to use.
 	Model Model `json:"model" binding:"required"`
 	// Prompt to generate completions for, encoded as a string, array of strings, array of tokens, or array of token arrays.
-	Prompt []string `json:"prompt" binding:"required"`
+	Prompt Prompt `json:"prompt" binding:"required"`
+	// The suffix that comes after a completion of inserted text.
+	Suffix string `json:"suffix,omitempty"`
 	// The maximum number of tokens to generate in the completion.
-	// The token count of your prompt plus max_tokens cannot exceed the model's context length.
-	// Most models have a context length of 2048 tokens (except for the newest models, which support 4096).
-	MaxTokens int `json:"max_tokens,omitempty" binding:"omitempty,max=4096"`
-	// What sampling temperature to use. Higher values means the model will take more risks.
+	// The token count of your prompt plus max_tokens cannot exceed the model's context length,
+	// see ModelInfo.ContextLength.
+	//
+	// Default: 1024
+	MaxTokens int `json:"max_tokens,omitempty" binding:"omitempty,min=1"`
+	// What sampling temperature to use, between 0 and 2. Higher values means the model will take more risks.
 	// Try 0.9 for more creative applications, and 0 (argmax sampling) for ones with a well-defined answer.
-	Temperature float32 `json:"temperature,omitempty"`
+	Temperature float32 `json:"temperature,omitempty" binding:"omitempty,min=0,max=2"`
+	// An alternative to sampling with temperature, called nucleus sampling, where the model considers
+	// the results of the tokens with top_p probability mass.
+	TopP float32 `json:"top_p,omitempty" binding:"omitempty,min=0,max=1"`
 	// How many completions to generate for each prompt.
-	N int `json:"n,omitempty"`
+	N int `json:"n,omitempty" binding:"omitempty,min=1,max=128"`
+	// Include the log probabilities on the logprobs most likely tokens, as well the chosen tokens.
+	// The maximum value is 5. Zero returns log probabilities of the chosen tokens only,
+	// nil means no log probabilities are returned.
+	Logprobs *int `json:"logprobs,omitempty" binding:"omitempty,min=0,max=5"`
+	// Echo back the prompt in addition to the completion.
+	Echo bool `json:"echo,omitempty"`
 	// Up to 4 sequences where the API will stop generating further tokens.
 	// The returned text will not contain the stop sequence.
-	Stop []string `json:"stop,omitempty"`
+	Stop []string `json:"stop,omitempty" binding:"omitempty,max=4"`
+	// Number between -2.0 and 2.0. Positive values penalize new tokens based on whether they appear
+	// in the text so far, increasing the model's likelihood to talk about new topics.
+	PresencePenalty float32 `json:"presence_penalty,omitempty" binding:"omitempty,min=-2,max=2"`
+	// Number between -2.0 and 2.0. Positive values penalize new tokens based on their existing frequency
+	// in the text so far, decreasing the model's likelihood to repeat the same line verbatim.
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty" binding:"omitempty,min=-2,max=2"`
+	// Generates best_of completions server-side and returns the best one (the one with the highest log probability per token).
+	// Must be greater than or equal to N. Results can't be streamed.
+	BestOf int `json:"best_of,omitempty" binding:"omitempty,min=1,max=20,gtefield=N"`
+	// Modify the likelihood of specified tokens appearing in the completion.
+	// Maps tokens (specified by their token ID in the tokenizer) to an associated bias value from -100 to 100.
+	LogitBias map[int]int `json:"logit_bias,omitempty" binding:"omitempty,dive,min=-100,max=100"`
+	// If specified, the system will make a best effort to sample deterministically, such that
+	// repeated requests with the same seed and parameters should return the same result.
+	Seed *int `json:"seed,omitempty"`
+	// A unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
+	User string `json:"user,omitempty"`
 }
 
 type CompletionResponse struct {
-	Id      string `json:"id"`
-	Object  string `json:"object"`
-	Created int    `json:"created"`
-	Model   Model  `json:"model"`
-	Choices []struct {
-		Text         string `json:"text"`
-		Index        int    `json:"index"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Id      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int                `json:"created"`
+	Model   Model              `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   Usage              `json:"usage"`
+}
+
+type CompletionChoice struct {
+	Text         string `json:"text"`
+	Index        int    `json:"index"`
+	FinishReason string `json:"finish_reason"`
+	// Log probabilities of the tokens, set if CompletionOptions.Logprobs is specified.
+	Logprobs *CompletionLogprobs `json:"logprobs"`
+}
+
+// CompletionLogprobs contains log probabilities of the completion tokens.
+// All slices have an element per token. If the prompt is echoed, values of the first token are zero,
+// because it has no log probability.
+type CompletionLogprobs struct {
+	// Tokens of the text.
+	Tokens []string `json:"tokens"`
+	// Log probability of each token.
+	TokenLogprobs []float64 `json:"token_logprobs"`
+	// Most likely tokens and their log probabilities at each position.
+	TopLogprobs []map[string]float64 `json:"top_logprobs"`
+	// Offset of each token in the text, in bytes.
+	TextOffset []int `json:"text_offset"`
+}
+
+// MeanLogprob returns the average log probability of the tokens, which can be used as a confidence score.
+// Use math.Exp to convert it to the probability.
+func (l *CompletionLogprobs) MeanLogprob() float64 {
+	if len(l.TokenLogprobs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range l.TokenLogprobs {
+		sum += p
+	}
+	return sum / float64(len(l.TokenLogprobs))
+}
+
+// withDefaults returns the copy of options with default values set, so the caller's options are not changed.
+func (opts *CompletionOptions) withDefaults() *CompletionOptions {
+	o := *opts
+	if o.MaxTokens == 0 {
+		o.MaxTokens = defaultMaxTokens
+	}
+	return &o
+}
+
+// Usage represents the token usage statistics of the request.
+type Usage struct {
+	// Number of tokens in the prompt.
+	PromptTokens int `json:"prompt_tokens"`
+	// Number of tokens in the generated completion.
+	CompletionTokens int `json:"completion_tokens"`
+	// Total number of tokens used in the request (prompt + completion).
+	TotalTokens int `json:"total_tokens"`
 }
 
 // Completion given a prompt, the model will return one or more predicted completions,
@@ -53,11 +249,17 @@ func (e *Engine) Completion(ctx context.Context, opts *CompletionOptions) (*Comp
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	uri := e.apiBaseURL + "/completions"
-	if opts.MaxTokens == 0 {
-		opts.MaxTokens = defaultMaxTokens
+	if err := checkModel(opts.Model, EndpointCompletions); err != nil {
+		return nil, err
+	}
+	if err := checkMaxTokens(opts.Model, opts.MaxTokens); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/completions", opts.Model)
+	if err != nil {
+		return nil, err
 	}
-	r, err := marshalJson(opts)
+	r, err := marshalJson(opts.withDefaults())
 	if err != nil {
 		return nil, err
 	}
@@ -74,5 +276,6 @@ func (e *Engine) Completion(ctx context.Context, opts *CompletionOptions) (*Comp
 	if err := unmarshal(resp, &jsonResp); err != nil {
 		return nil, err
 	}
+	e.stats.recordUsage(jsonResp.Usage)
 	return &jsonResp, nil
 }
@@ -6,25 +6,176 @@ package openai
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
-func TestCompletion(t *testing.T) {
-	e := New(os.Getenv("OPENAI_KEY"))
+func TestCompletionLogprobs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/completions", r.URL.Path)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, " world", body["suffix"])
+		assert.Equal(t, float64(2), body["logprobs"])
+		assert.Equal(t, true, body["echo"])
+		assert.Equal(t, float64(3), body["best_of"])
+		assert.Equal(t, float64(42), body["seed"])
+		assert.Equal(t, "user-1", body["user"])
+		assert.Equal(t, map[string]interface{}{"50256": float64(-100)}, body["logit_bias"])
+		w.Write([]byte(`{
+			"id": "cmpl-1",
+			"object": "text_completion",
+			"model": "text-davinci-003",
+			"choices": [{
+				"text": "Hello there",
+				"index": 0,
+				"finish_reason": "length",
+				"logprobs": {
+					"tokens": ["Hello", " there"],
+					"token_logprobs": [null, -0.5],
+					"top_logprobs": [null, {" there": -0.5, " world": -1.2}],
+					"text_offset": [0, 5]
+				}
+			}],
+			"usage": {"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+		}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	logprobs, seed := 2, 42
 	r, err := e.Completion(context.Background(), &CompletionOptions{
-		Model: DefaultModel,
-		Prompt: []string{`Write a thorough blog post outline with at least 8 sections and a unique structure for a blog post titled “The global recession cases & consequences”. 
-		Avoid lists. Do not be repetitive. The tone should be educational. The audience is intermediate in the subject. Other information to know is “Will be a global recession in 2023? 
-		Or has this process already started?”. In your response, do not say anything additional, just provide the outline.`},
+		Model:     DefaultModel,
+		Prompt:    PromptText("Hello"),
+		Suffix:    " world",
+		Logprobs:  &logprobs,
+		Echo:      true,
+		BestOf:    3,
+		LogitBias: map[int]int{50256: -100},
+		Seed:      &seed,
+		User:      "user-1",
 	})
-	if err != nil {
-		log.Fatal(err)
+	assert.NoError(t, err)
+	lp := r.Choices[0].Logprobs
+	if assert.NotNil(t, lp) {
+		assert.Equal(t, []string{"Hello", " there"}, lp.Tokens)
+		assert.Equal(t, []float64{0, -0.5}, lp.TokenLogprobs)
+		assert.Nil(t, lp.TopLogprobs[0])
+		assert.Equal(t, -1.2, lp.TopLogprobs[1][" world"])
+		assert.Equal(t, []int{0, 5}, lp.TextOffset)
+		assert.Equal(t, -0.25, lp.MeanLogprob())
+		assert.InDelta(t, 0.78, math.Exp(lp.MeanLogprob()), 0.01)
+	}
+}
+
+func TestCompletionOptionsValues(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"choices":[{"text":"Hi"}]}`))
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	// Zero logprobs and seed are sent, the default max_tokens isn't written to the options
+	var zero int
+	opts := &CompletionOptions{Model: DefaultModel, Prompt: PromptText("Hello"), Logprobs: &zero, Seed: &zero}
+	_, err := e.Completion(ctx, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), body["logprobs"])
+	assert.Equal(t, float64(0), body["seed"])
+	assert.Equal(t, float64(defaultMaxTokens), body["max_tokens"])
+	assert.Zero(t, opts.MaxTokens)
+
+	_, err = e.Completion(ctx, &CompletionOptions{Model: DefaultModel, Prompt: PromptText("Hello")})
+	assert.NoError(t, err)
+	assert.NotContains(t, body, "logprobs")
+	assert.NotContains(t, body, "seed")
+
+	// max_tokens is limited by the context length of the model
+	_, err = e.Completion(ctx, &CompletionOptions{Model: ModelDavinci002, Prompt: PromptText("Hello"), MaxTokens: 16000})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(16000), body["max_tokens"])
+	_, err = e.Completion(ctx, &CompletionOptions{Model: ModelDavinci002, Prompt: PromptText("Hello"), MaxTokens: 16385})
+	assert.ErrorIs(t, err, ErrContextLengthExceeded)
+	_, err = e.StreamCompletion(ctx, &CompletionOptions{Model: ModelGPT3TextDavinci003, Prompt: PromptText("Hello"), MaxTokens: 5000})
+	assert.ErrorIs(t, err, ErrContextLengthExceeded)
+}
+
+func TestCompletionOptionsValidation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid options must not be sent")
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	valid := func() *CompletionOptions {
+		return &CompletionOptions{Model: DefaultModel, Prompt: PromptText("Hello")}
+	}
+	testCases := []struct {
+		name   string
+		modify func(*CompletionOptions)
+	}{
+		{"best_of less than n", func(o *CompletionOptions) { o.N, o.BestOf = 3, 2 }},
+		{"too many stop sequences", func(o *CompletionOptions) { o.Stop = []string{"a", "b", "c", "d", "e"} }},
+		{"logprobs above 5", func(o *CompletionOptions) { o.Logprobs = new(int); *o.Logprobs = 6 }},
+		{"top_p above 1", func(o *CompletionOptions) { o.TopP = 1.5 }},
+		{"presence penalty below -2", func(o *CompletionOptions) { o.PresencePenalty = -3 }},
+		{"logit bias above 100", func(o *CompletionOptions) { o.LogitBias = map[int]int{1: 101} }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := valid()
+			tc.modify(opts)
+			_, err := e.Completion(context.Background(), opts)
+			assert.Error(t, err)
+		})
+	}
+
+	opts := valid()
+	opts.BestOf = 2
+	_, err := e.StreamCompletion(context.Background(), opts)
+	assert.Error(t, err)
+}
+
+func TestPrompt(t *testing.T) {
+	testCases := []struct {
+		name   string
+		prompt Prompt
+		want   string
+		len    int
+	}{
+		{"text", PromptText("Hello"), `"Hello"`, 1},
+		{"texts", PromptTexts("Hello", "World"), `["Hello","World"]`, 2},
+		{"tokens", PromptTokens(9906, 1917), `[9906,1917]`, 1},
+		{"token arrays", PromptTokenArrays([]int{9906}, []int{10343, 0}), `[[9906],[10343,0]]`, 2},
 	}
-	if b, err := json.MarshalIndent(r, "", "  "); err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println(string(b))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.prompt)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(b))
+			assert.Equal(t, tc.len, tc.prompt.Len())
+
+			var p Prompt
+			assert.NoError(t, json.Unmarshal(b, &p))
+			assert.Equal(t, tc.prompt, p)
+		})
 	}
+
+	var p Prompt
+	assert.Error(t, json.Unmarshal([]byte(`{"text":"Hello"}`), &p))
+
+	// Empty prompt is rejected before sending
+	e := New("test")
+	_, err := e.Completion(context.Background(), &CompletionOptions{Model: DefaultModel})
+	assert.Error(t, err)
+	_, err = e.Completion(context.Background(), &CompletionOptions{Model: DefaultModel, Prompt: PromptTokens()})
+	assert.Error(t, err)
 }
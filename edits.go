@@ -32,11 +32,7 @@ type EditResponse struct {
 		Index        int    `json:"index"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage Usage `json:"usage"`
 }
 
 // Edit given a prompt and an instruction, the model will return an edited version of the prompt.
@@ -49,12 +45,18 @@ func (e *Engine) Edit(ctx context.Context, opts *EditOptions) (*EditResponse, er
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	url := e.apiBaseURL + "/edits"
+	if err := checkModel(opts.Model, EndpointEdits); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/edits", opts.Model)
+	if err != nil {
+		return nil, err
+	}
 	r, err := marshalJson(opts)
 	if err != nil {
 		return nil, err
 	}
-	req, err := e.newReq(ctx, http.MethodPost, url, "json", r)
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", r)
 	if err != nil {
 		return nil, err
 	}
@@ -66,5 +68,6 @@ func (e *Engine) Edit(ctx context.Context, opts *EditOptions) (*EditResponse, er
 	if err := unmarshal(resp, &jsonResp); err != nil {
 		return nil, err
 	}
+	e.stats.recordUsage(jsonResp.Usage)
 	return &jsonResp, nil
 }
@@ -0,0 +1,296 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// MaxEmbeddingInputs is the maximum number of inputs in a single embeddings request.
+const MaxEmbeddingInputs = 2048
+
+// MaxEmbeddingTokens is the maximum number of tokens of all inputs in a single embeddings request.
+const MaxEmbeddingTokens = 300000
+
+// EncodingFormat represents the format of returned embeddings.
+type EncodingFormat string
+
+const (
+	EncodingFormatFloat  EncodingFormat = "float"
+	EncodingFormatBase64 EncodingFormat = "base64"
+)
+
+// EmbeddingInput is the input to embed, encoded as an array of strings or an array of token arrays.
+// Use EmbeddingText or EmbeddingTokens to create it.
+type EmbeddingInput struct {
+	texts  []string
+	tokens [][]int
+}
+
+// EmbeddingText creates the input from one or more texts.
+func EmbeddingText(texts ...string) EmbeddingInput {
+	return EmbeddingInput{texts: texts}
+}
+
+// EmbeddingTokens creates the input from one or more token arrays.
+func EmbeddingTokens(tokens ...[]int) EmbeddingInput {
+	return EmbeddingInput{tokens: tokens}
+}
+
+// Len returns the number of inputs.
+func (in EmbeddingInput) Len() int {
+	if in.tokens != nil {
+		return len(in.tokens)
+	}
+	return len(in.texts)
+}
+
+func (in EmbeddingInput) slice(i, j int) EmbeddingInput {
+	if in.tokens != nil {
+		return EmbeddingInput{tokens: in.tokens[i:j]}
+	}
+	return EmbeddingInput{texts: in.texts[i:j]}
+}
+
+func (in EmbeddingInput) MarshalJSON() ([]byte, error) {
+	if in.tokens != nil {
+		return json.Marshal(in.tokens)
+	}
+	return json.Marshal(in.texts)
+}
+
+// embeddingInputValue lets validator check EmbeddingInput by the number of inputs.
+func embeddingInputValue(v reflect.Value) interface{} {
+	if in, ok := v.Interface().(EmbeddingInput); ok {
+		return in.Len()
+	}
+	return nil
+}
+
+type EmbeddingOptions struct {
+	// ID of the model to use.
+	Model Model `json:"model" binding:"required"`
+	// Input text to embed. Each input must not exceed the max input tokens for the model
+	// and the number of inputs must not exceed MaxEmbeddingInputs.
+	Input EmbeddingInput `json:"input" binding:"required,max=2048"`
+	// The format to return the embeddings in. Can be either float or base64.
+	// Either way embeddings are decoded to []float32.
+	EncodingFormat EncodingFormat `json:"encoding_format,omitempty" binding:"omitempty,oneof=float base64"`
+	// The number of dimensions the resulting output embeddings should have.
+	// Only supported in text-embedding-3 and later models.
+	Dimensions int `json:"dimensions,omitempty" binding:"omitempty,min=1"`
+	// A unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
+	User string `json:"user,omitempty"`
+}
+
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  Model       `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+type Embedding struct {
+	Object string `json:"object"`
+	// The index of the input in the list of inputs.
+	Index int `json:"index"`
+	// The embedding vector.
+	Embedding []float32 `json:"embedding"`
+}
+
+// UnmarshalJSON decodes the embedding vector encoded as an array of floats or as base64 string.
+func (emb *Embedding) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Object    string          `json:"object"`
+		Index     int             `json:"index"`
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	emb.Object = v.Object
+	emb.Index = v.Index
+	if len(v.Embedding) != 0 && v.Embedding[0] == '"' {
+		var s string
+		if err := json.Unmarshal(v.Embedding, &s); err != nil {
+			return err
+		}
+		vec, err := decodeBase64Vector(s)
+		if err != nil {
+			return err
+		}
+		emb.Embedding = vec
+		return nil
+	}
+	return json.Unmarshal(v.Embedding, &emb.Embedding)
+}
+
+// decodeBase64Vector decodes base64 encoded little-endian float32 array.
+func decodeBase64Vector(s string) ([]float32, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 embedding: %w", err)
+	}
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("decode base64 embedding: invalid length %d", len(b))
+	}
+	vec := make([]float32, len(b)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return vec, nil
+}
+
+// Embeddings creates an embedding vector representing the input text.
+//
+// Docs: https://platform.openai.com/docs/api-reference/embeddings/create
+func (e *Engine) Embeddings(ctx context.Context, opts *EmbeddingOptions) (*EmbeddingResponse, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	if err := checkModel(opts.Model, EndpointEmbeddings); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/embeddings", opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	r, err := marshalJson(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp EmbeddingResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	e.stats.recordUsage(jsonResp.Usage)
+	return &jsonResp, nil
+}
+
+// EmbeddingsBatch is the same as Embeddings, but splits the input into batches of at most batchSize
+// inputs and MaxEmbeddingTokens tokens and sends them one by one. Embeddings in the response are
+// in the same order as the inputs and usage is summed over all batches.
+//
+// Tokens of texts are counted with the model tokenizer. If an input is longer than the context
+// length of the model, an error wrapping ErrContextLengthExceeded is returned before sending requests.
+// If batchSize is not positive or greater than MaxEmbeddingInputs, MaxEmbeddingInputs is used.
+func (e *Engine) EmbeddingsBatch(ctx context.Context, opts *EmbeddingOptions, batchSize int) (*EmbeddingResponse, error) {
+	if batchSize <= 0 || batchSize > MaxEmbeddingInputs {
+		batchSize = MaxEmbeddingInputs
+	}
+	n := opts.Input.Len()
+	if n == 0 {
+		// Let validation report the empty input
+		return e.Embeddings(ctx, opts)
+	}
+	ends, err := splitEmbeddingInput(opts.Model, opts.Input, batchSize, MaxEmbeddingTokens)
+	if err != nil {
+		return nil, err
+	}
+	result := &EmbeddingResponse{
+		Data: make([]Embedding, 0, n),
+	}
+	var i int
+	for _, j := range ends {
+		batchOpts := *opts
+		batchOpts.Input = opts.Input.slice(i, j)
+		resp, err := e.Embeddings(ctx, &batchOpts)
+		if err != nil {
+			return nil, fmt.Errorf("batch %d-%d: %w", i, j, err)
+		}
+		if len(resp.Data) != j-i {
+			return nil, fmt.Errorf("batch %d-%d: expected %d embeddings, got %d", i, j, j-i, len(resp.Data))
+		}
+		sort.Slice(resp.Data, func(a, b int) bool {
+			return resp.Data[a].Index < resp.Data[b].Index
+		})
+		for _, emb := range resp.Data {
+			emb.Index += i
+			result.Data = append(result.Data, emb)
+		}
+		result.Object = resp.Object
+		result.Model = resp.Model
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+		i = j
+	}
+	return result, nil
+}
+
+// splitEmbeddingInput returns end indexes of batches of at most batchSize inputs and maxTokens tokens.
+func splitEmbeddingInput(model Model, in EmbeddingInput, batchSize int, maxTokens int) ([]int, error) {
+	contextLength := LookupModel(model).ContextLength
+	var (
+		ends   []int
+		start  int
+		tokens int
+	)
+	for i := 0; i < in.Len(); i++ {
+		var n int
+		if in.tokens != nil {
+			n = len(in.tokens[i])
+		} else {
+			n = estimateTokens(model, in.texts[i])
+		}
+		if contextLength != 0 && n > contextLength {
+			return nil, fmt.Errorf("input %d: %w: %d tokens, %s context length is %d", i, ErrContextLengthExceeded, n, model, contextLength)
+		}
+		if i > start && (i-start == batchSize || tokens+n > maxTokens) {
+			ends = append(ends, i)
+			start, tokens = i, 0
+		}
+		tokens += n
+	}
+	return append(ends, in.Len()), nil
+}
+
+// DotProduct returns the dot product of two vectors.
+// It panics if vectors have different lengths.
+func DotProduct(a, b []float32) float32 {
+	if len(a) != len(b) {
+		panic("openai: vectors have different lengths")
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return float32(sum)
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors, in range [-1, 1].
+// It returns 0 if one of vectors is zero and panics if vectors have different lengths.
+//
+// OpenAI embeddings are normalized to length 1, so DotProduct gives the same result faster.
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		panic("openai: vectors have different lengths")
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
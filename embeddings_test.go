@@ -0,0 +1,176 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeBase64Vector(vec []float32) string {
+	b := make([]byte, len(vec)*4)
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestEmbeddings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		var body struct {
+			Input          [][]int `json:"input"`
+			EncodingFormat string  `json:"encoding_format"`
+			Dimensions     int     `json:"dimensions"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, [][]int{{1, 2, 3}}, body.Input)
+		assert.Equal(t, "base64", body.EncodingFormat)
+		assert.Equal(t, 3, body.Dimensions)
+		fmt.Fprintf(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":%q}],
+			"model":"text-embedding-3-small","usage":{"prompt_tokens":3,"total_tokens":3}}`,
+			encodeBase64Vector([]float32{0.5, -0.25, 1}))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	r, err := e.Embeddings(context.Background(), &EmbeddingOptions{
+		Model:          ModelTextEmbedding3Small,
+		Input:          EmbeddingTokens([]int{1, 2, 3}),
+		EncodingFormat: EncodingFormatBase64,
+		Dimensions:     3,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0.5, -0.25, 1}, r.Data[0].Embedding)
+	assert.Equal(t, 3, r.Usage.TotalTokens)
+
+	_, err = e.Embeddings(context.Background(), &EmbeddingOptions{
+		Model: ModelTextEmbedding3Small,
+		Input: EmbeddingText(),
+	})
+	assert.Error(t, err, "empty input must not pass validation")
+}
+
+func TestEmbeddingsBatch(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var body struct {
+			Input []string `json:"input"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.LessOrEqual(t, len(body.Input), 2)
+		// Return embeddings in reverse order, the vector holds the length of input
+		var resp EmbeddingResponse
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, Embedding{Index: i, Embedding: []float32{float32(len(body.Input[i]))}})
+		}
+		resp.Usage = Usage{PromptTokens: len(body.Input), TotalTokens: len(body.Input)}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	r, err := e.EmbeddingsBatch(context.Background(), &EmbeddingOptions{
+		Model: ModelTextEmbeddingAda002,
+		Input: EmbeddingText("a", "bb", "ccc", "dddd", "eeeee"),
+	}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Len(t, r.Data, 5)
+	for i, emb := range r.Data {
+		assert.Equal(t, i, emb.Index)
+		assert.Equal(t, []float32{float32(i + 1)}, emb.Embedding)
+	}
+	assert.Equal(t, 5, r.Usage.TotalTokens)
+}
+
+func TestSplitEmbeddingInput(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     EmbeddingInput
+		batchSize int
+		maxTokens int
+		want      []int
+	}{
+		{"by inputs", EmbeddingTokens([]int{1}, []int{2}, []int{3}, []int{4}, []int{5}), 2, 100, []int{2, 4, 5}},
+		{"by tokens", EmbeddingTokens([]int{1, 2, 3}, []int{4, 5}, []int{6}, []int{7, 8, 9, 10}), 10, 5, []int{2, 4}},
+		{"input of max tokens", EmbeddingTokens([]int{1}, []int{2, 3, 4}, []int{5}), 10, 3, []int{1, 2, 3}},
+		{"texts", EmbeddingText("hello world", "hello world", "hello world"), 10, 4, []int{2, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ends, err := splitEmbeddingInput(ModelTextEmbeddingAda002, tc.input, tc.batchSize, tc.maxTokens)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, ends)
+		})
+	}
+
+	// Input longer than the context length can't be split
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request must not be sent")
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	_, err := e.EmbeddingsBatch(context.Background(), &EmbeddingOptions{
+		Model: ModelTextEmbeddingAda002,
+		Input: EmbeddingTokens([]int{1}, make([]int, 8192)),
+	}, 0)
+	assert.ErrorIs(t, err, ErrContextLengthExceeded)
+}
+
+func TestVectorSimilarity(t *testing.T) {
+	testCases := []struct {
+		name       string
+		a, b       []float32
+		wantDot    float32
+		wantCosine float32
+	}{
+		{
+			name:       "same direction",
+			a:          []float32{1, 2, 3},
+			b:          []float32{2, 4, 6},
+			wantDot:    28,
+			wantCosine: 1,
+		},
+		{
+			name:       "orthogonal",
+			a:          []float32{1, 0},
+			b:          []float32{0, 1},
+			wantDot:    0,
+			wantCosine: 0,
+		},
+		{
+			name:       "opposite",
+			a:          []float32{1, 1},
+			b:          []float32{-1, -1},
+			wantDot:    -2,
+			wantCosine: -1,
+		},
+		{
+			name:       "zero vector",
+			a:          []float32{0, 0},
+			b:          []float32{1, 1},
+			wantDot:    0,
+			wantCosine: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.wantDot, DotProduct(tc.a, tc.b), 1e-6)
+			assert.InDelta(t, tc.wantCosine, CosineSimilarity(tc.a, tc.b), 1e-6)
+		})
+	}
+	assert.Panics(t, func() { DotProduct([]float32{1}, []float32{1, 2}) })
+}
@@ -3,20 +3,210 @@
 // that can be found in the LICENSE file.
 package openai
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
 
+// Sentinel errors which can be matched against the API error using errors.Is.
+var (
+	// ErrRateLimited is returned when too many requests or tokens were sent in a short period of time.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrQuotaExceeded is returned when the organization ran out of credits or hit its monthly spend limit.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrContextLengthExceeded is returned when the prompt plus the completion exceed the model's context length.
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+	// ErrInvalidAPIKey is returned when the API key is invalid, expired or revoked.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrModelNotFound is returned when the model doesn't exist or the user doesn't have access to it.
+	// On Azure, it's returned when the deployment doesn't exist.
+	ErrModelNotFound = errors.New("model not found")
+	// ErrContentFiltered is returned when Azure OpenAI content filter blocked the prompt or the completion.
+	ErrContentFiltered = errors.New("content filtered")
+)
+
+const (
+	// maxErrorBodySize is the maximum number of bytes read from error response body.
+	maxErrorBodySize = 1 << 20
+	// maxErrorMessageSize is the maximum length of the message made from not JSON error body.
+	maxErrorMessageSize = 512
+)
+
+// APIError is returned when the API responds with not-success HTTP status code.
 type APIError struct {
-	Err struct {
-		StatusCode int    `json:"status_code"`
-		Message    string `json:"message"`
-		Type       string `json:"type"`
-	} `json:"error"`
+	// HTTP status code of the response.
+	StatusCode int
+	// Type of the error, e.g. invalid_request_error.
+	Type string
+	// Machine-readable error code, e.g. context_length_exceeded.
+	Code string
+	// Name of the request parameter which caused the error, if any.
+	Param string
+	// Human-readable error message.
+	Message string
+	// Code of the inner error returned by Azure OpenAI, e.g. ResponsibleAIPolicyViolation.
+	InnerCode string
+	// Results of Azure OpenAI content filter by category, e.g. hate or violence.
+	// Set when Code is content_filter.
+	ContentFilterResults map[string]ContentFilterResult
+	// ID of the request from x-request-id header (apim-request-id on Azure).
+	// Useful to report issues to OpenAI.
+	RequestID string
+	// Headers of the response.
+	Header http.Header
+}
+
+// ContentFilterResult is the result of Azure OpenAI content filter for a category.
+type ContentFilterResult struct {
+	Filtered bool `json:"filtered"`
+	// Severity of the content, e.g. safe, low, medium or high.
+	Severity string `json:"severity,omitempty"`
+	// Whether the content was detected, for categories without severity, e.g. jailbreak.
+	Detected bool `json:"detected,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status code %d", e.StatusCode)
+	switch {
+	case e.Code != "":
+		fmt.Fprintf(&b, ", code %s", e.Code)
+	case e.Type != "":
+		fmt.Fprintf(&b, ", type %s", e.Type)
+	}
+	if e.Param != "" {
+		fmt.Fprintf(&b, ", param %s", e.Param)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// Is reports whether the error matches one of the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests && e.Code != "insufficient_quota"
+	case ErrQuotaExceeded:
+		return e.Code == "insufficient_quota"
+	case ErrContextLengthExceeded:
+		return e.Code == "context_length_exceeded"
+	case ErrInvalidAPIKey:
+		// Azure reports the status code as the error code
+		return e.Code == "invalid_api_key" ||
+			(e.StatusCode == http.StatusUnauthorized && (e.Code == "" || e.Code == "401"))
+	case ErrModelNotFound:
+		return e.Code == "model_not_found" || e.Code == "DeploymentNotFound"
+	case ErrContentFiltered:
+		return e.Code == "content_filter"
+	}
+	return false
+}
+
+// UnmarshalJSON decodes the error from the {"error": {...}} envelope returned by the API.
+// The {"statusCode": 401, "message": "..."} error of Azure API Management gateway is supported too.
+func (e *APIError) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Error) == 0 || bytes.Equal(v.Error, []byte("null")) {
+		if v.Message != "" {
+			e.Message = v.Message
+			return nil
+		}
+		return errors.New("missing error object")
+	}
+	// Some OpenAI-compatible servers return the error as a plain string
+	if err := json.Unmarshal(v.Error, &e.Message); err == nil {
+		return nil
+	}
+	var details struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Param   *string         `json:"param"`
+		Code    json.RawMessage `json:"code"`
+		// Azure OpenAI specific details
+		InnerError *struct {
+			Code                 string                         `json:"code"`
+			ContentFilterResults map[string]ContentFilterResult `json:"content_filter_result"`
+		} `json:"innererror"`
+	}
+	if err := json.Unmarshal(v.Error, &details); err != nil {
+		return err
+	}
+	if details.InnerError != nil {
+		e.InnerCode = details.InnerError.Code
+		e.ContentFilterResults = details.InnerError.ContentFilterResults
+	}
+	e.Message = details.Message
+	e.Type = details.Type
+	if details.Param != nil {
+		e.Param = *details.Param
+	}
+	e.Code = rawString(details.Code)
+	return nil
+}
+
+// rawString returns JSON string or number as string.
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// newAPIError reads the error from the not-success response and closes its body.
+// If the body is not a JSON error (e.g. HTML page from a gateway), the message is made of the body text.
+func newAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	apiErr := &APIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = errorBodyMessage(body)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	apiErr.StatusCode = resp.StatusCode
+	apiErr.RequestID = requestID(resp.Header)
+	apiErr.Header = resp.Header
+	return apiErr
+}
+
+// requestID returns ID of the request from response headers.
+func requestID(h http.Header) string {
+	if id := h.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return h.Get("Apim-Request-Id")
 }
 
-func (e APIError) Error() string {
-	b, err := json.Marshal(e)
-	if err != nil {
-		return "undefined error"
+func errorBodyMessage(body []byte) string {
+	msg := strings.Join(strings.Fields(string(body)), " ")
+	if len(msg) > maxErrorMessageSize {
+		msg = msg[:maxErrorMessageSize]
+		for !utf8.ValidString(msg) {
+			msg = msg[:len(msg)-1]
+		}
+		msg += "..."
 	}
-	return string(b)
+	return msg
 }
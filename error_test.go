@@ -0,0 +1,149 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		statusCode  int
+		body        string
+		wantErr     *APIError
+		wantIs      error
+		wantMessage string
+	}{
+		{
+			name:       "error:rate limited",
+			statusCode: http.StatusTooManyRequests,
+			body:       `{"error":{"message":"Rate limit reached","type":"requests","param":null,"code":"rate_limit_exceeded"}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "requests",
+				Code:       "rate_limit_exceeded",
+				Message:    "Rate limit reached",
+				RequestID:  "req-123",
+			},
+			wantIs:      ErrRateLimited,
+			wantMessage: "status code 429, code rate_limit_exceeded: Rate limit reached (request id req-123)",
+		},
+		{
+			name:       "error:quota exceeded",
+			statusCode: http.StatusTooManyRequests,
+			body:       `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","param":null,"code":"insufficient_quota"}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Type:       "insufficient_quota",
+				Code:       "insufficient_quota",
+				Message:    "You exceeded your current quota",
+				RequestID:  "req-123",
+			},
+			wantIs: ErrQuotaExceeded,
+		},
+		{
+			name:       "error:context length exceeded",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":{"message":"This model's maximum context length is 4097 tokens","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusBadRequest,
+				Type:       "invalid_request_error",
+				Code:       "context_length_exceeded",
+				Param:      "messages",
+				Message:    "This model's maximum context length is 4097 tokens",
+				RequestID:  "req-123",
+			},
+			wantIs: ErrContextLengthExceeded,
+		},
+		{
+			name:       "error:invalid api key",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusUnauthorized,
+				Type:       "invalid_request_error",
+				Code:       "invalid_api_key",
+				Message:    "Incorrect API key provided",
+				RequestID:  "req-123",
+			},
+			wantIs: ErrInvalidAPIKey,
+		},
+		{
+			name:       "error:model not found",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"message":"The model gpt-5 does not exist","type":"invalid_request_error","param":null,"code":"model_not_found"}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusNotFound,
+				Type:       "invalid_request_error",
+				Code:       "model_not_found",
+				Message:    "The model gpt-5 does not exist",
+				RequestID:  "req-123",
+			},
+			wantIs: ErrModelNotFound,
+		},
+		{
+			name:       "error:numeric code",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error":{"message":"Internal error","type":"server_error","code":500}}`,
+			wantErr: &APIError{
+				StatusCode: http.StatusInternalServerError,
+				Type:       "server_error",
+				Code:       "500",
+				Message:    "Internal error",
+				RequestID:  "req-123",
+			},
+		},
+		{
+			name:       "error:html body from gateway",
+			statusCode: http.StatusBadGateway,
+			body:       "<html>\n<head><title>502 Bad Gateway</title></head>\n</html>\n",
+			wantErr: &APIError{
+				StatusCode: http.StatusBadGateway,
+				Message:    "<html> <head><title>502 Bad Gateway</title></head> </html>",
+				RequestID:  "req-123",
+			},
+		},
+		{
+			name:       "error:empty body",
+			statusCode: http.StatusServiceUnavailable,
+			wantErr: &APIError{
+				StatusCode: http.StatusServiceUnavailable,
+				Message:    "Service Unavailable",
+				RequestID:  "req-123",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Id", "req-123")
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			e := NewWithOptions("test", WithBaseURL(srv.URL))
+			_, err := e.ListModels(context.Background())
+			var apiErr *APIError
+			assert.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, "req-123", apiErr.Header.Get("X-Request-Id"))
+			apiErr.Header = nil
+			assert.Equal(t, tc.wantErr, apiErr)
+			if tc.wantIs != nil {
+				assert.ErrorIs(t, err, tc.wantIs)
+			}
+			if tc.wantMessage != "" {
+				assert.Equal(t, tc.wantMessage, err.Error())
+			}
+		})
+	}
+}
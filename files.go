@@ -0,0 +1,277 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// FilePurpose represents the intended purpose of the uploaded file.
+type FilePurpose string
+
+const (
+	FilePurposeFineTune        FilePurpose = "fine-tune"
+	FilePurposeFineTuneResults FilePurpose = "fine-tune-results"
+	FilePurposeAssistants      FilePurpose = "assistants"
+	FilePurposeBatch           FilePurpose = "batch"
+	FilePurposeBatchOutput     FilePurpose = "batch_output"
+	FilePurposeVision          FilePurpose = "vision"
+)
+
+// File represents a document that has been uploaded to OpenAI.
+type File struct {
+	Id     string `json:"id"`
+	Object string `json:"object"`
+	// The size of the file, in bytes.
+	Bytes int64 `json:"bytes"`
+	// The Unix timestamp (in seconds) for when the file was created.
+	CreatedAt int64       `json:"created_at"`
+	Filename  string      `json:"filename"`
+	Purpose   FilePurpose `json:"purpose"`
+}
+
+type UploadFileOptions struct {
+	// The file to upload. It is streamed to the API without buffering in memory.
+	// If it implements io.Seeker, the upload can be retried.
+	File io.Reader `binding:"required"`
+	// The name of the file, e.g. train.jsonl.
+	Filename string `binding:"required"`
+	// The intended purpose of the uploaded file.
+	// Use fine-tune for fine-tuning and batch for batch API.
+	Purpose FilePurpose `binding:"required"`
+}
+
+// UploadFile uploads a file that can be used across various endpoints.
+//
+// Docs: https://platform.openai.com/docs/api-reference/files/create
+func (e *Engine) UploadFile(ctx context.Context, opts *UploadFileOptions) (*File, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/files")
+	body, contentType, err := newUploadFileBody(opts)
+	if err != nil {
+		return nil, err
+	}
+	r, err := body()
+	if err != nil {
+		return nil, err
+	}
+	req, err := e.newReq(ctx, http.MethodPost, uri, contentType, r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if _, ok := opts.File.(io.Seeker); ok {
+		req.GetBody = body
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp File
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// newUploadFileBody returns function which creates multipart body streaming the file through the pipe.
+// If the file is io.Seeker, each call rewinds it to the initial offset.
+func newUploadFileBody(opts *UploadFileOptions) (func() (io.ReadCloser, error), string, error) {
+	var offset int64
+	seeker, isSeeker := opts.File.(io.Seeker)
+	if isSeeker {
+		var err error
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, "", fmt.Errorf("seek file: %w", err)
+		}
+	}
+	boundary := multipart.NewWriter(nil).Boundary()
+	var prev *io.PipeReader
+	var prevDone chan struct{}
+	body := func() (io.ReadCloser, error) {
+		if prev != nil {
+			// Stop writing of the previous body before rewinding the file
+			prev.Close()
+			<-prevDone
+		}
+		if isSeeker {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("seek file: %w", err)
+			}
+		}
+		pr, pw := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			pw.CloseWithError(writeUploadFileBody(pw, boundary, opts))
+		}()
+		prev, prevDone = pr, done
+		return pr, nil
+	}
+	return body, "multipart/form-data; boundary=" + boundary, nil
+}
+
+func writeUploadFileBody(w io.Writer, boundary string, opts *UploadFileOptions) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
+	}
+	if err := writer.WriteField("purpose", string(opts.Purpose)); err != nil {
+		return fmt.Errorf("write purpose: %w", err)
+	}
+	file, err := writer.CreateFormFile("file", opts.Filename)
+	if err != nil {
+		return fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := io.Copy(file, opts.File); err != nil {
+		return fmt.Errorf("copy file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close writer: %w", err)
+	}
+	return nil
+}
+
+type ListFilesOptions struct {
+	// Only return files with the given purpose.
+	Purpose FilePurpose
+	// A limit on the number of objects to be returned.
+	// Limit can range between 1 and 10000.
+	Limit int `binding:"omitempty,min=1,max=10000"`
+	// Cursor for pagination, ID of the last file from the previous page.
+	After string
+	// Sort order by the created_at timestamp of the objects.
+	// Must be one of asc or desc.
+	Order string `binding:"omitempty,oneof=asc desc"`
+}
+
+type ListFilesResponse struct {
+	Object  string `json:"object"`
+	Data    []File `json:"data"`
+	FirstId string `json:"first_id"`
+	LastId  string `json:"last_id"`
+	// Whether there are more files after this page.
+	// Pass LastId as After to get the next page.
+	HasMore bool `json:"has_more"`
+}
+
+// ListFiles returns a list of files that belong to the user's organization.
+// Options are optional.
+//
+// Docs: https://platform.openai.com/docs/api-reference/files/list
+func (e *Engine) ListFiles(ctx context.Context, opts *ListFilesOptions) (*ListFilesResponse, error) {
+	if opts == nil {
+		opts = &ListFilesOptions{}
+	}
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if opts.Purpose != "" {
+		query.Set("purpose", string(opts.Purpose))
+	}
+	if opts.Limit != 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After != "" {
+		query.Set("after", opts.After)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+	uri := withQuery(e.apiURL("/files"), query)
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp ListFilesResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// RetrieveFile returns information about a specific file.
+//
+// Docs: https://platform.openai.com/docs/api-reference/files/retrieve
+func (e *Engine) RetrieveFile(ctx context.Context, fileId string) (*File, error) {
+	if err := e.validate.VarCtx(ctx, fileId, "required"); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/files/" + url.PathEscape(fileId))
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp File
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// RetrieveFileContent returns the contents of the specified file.
+// The caller must close the returned reader.
+//
+// Docs: https://platform.openai.com/docs/api-reference/files/retrieve-contents
+func (e *Engine) RetrieveFileContent(ctx context.Context, fileId string) (io.ReadCloser, error) {
+	if err := e.validate.VarCtx(ctx, fileId, "required"); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/files/" + url.PathEscape(fileId) + "/content")
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type DeleteFileResponse struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// DeleteFile deletes a file.
+//
+// Docs: https://platform.openai.com/docs/api-reference/files/delete
+func (e *Engine) DeleteFile(ctx context.Context, fileId string) (*DeleteFileResponse, error) {
+	if err := e.validate.VarCtx(ctx, fileId, "required"); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/files/" + url.PathEscape(fileId))
+	req, err := e.newReq(ctx, http.MethodDelete, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp DeleteFileResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
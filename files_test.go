@@ -0,0 +1,165 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadFile(t *testing.T) {
+	const content = `{"messages":[{"role":"user","content":"Hello"}]}` + "\n"
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/files", r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "fine-tune", r.FormValue("purpose"))
+		f, h, err := r.FormFile("file")
+		assert.NoError(t, err)
+		assert.Equal(t, "train.jsonl", h.Filename)
+		b, _ := io.ReadAll(f)
+		assert.Equal(t, content, string(b))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"file-abc123","object":"file","bytes":50,"created_at":1677610602,"filename":"train.jsonl","purpose":"fine-tune"}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	f, err := e.UploadFile(context.Background(), &UploadFileOptions{
+		File:     strings.NewReader(content),
+		Filename: "train.jsonl",
+		Purpose:  FilePurposeFineTune,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts, "seekable file must be uploaded again")
+	assert.Equal(t, "file-abc123", f.Id)
+	assert.Equal(t, FilePurposeFineTune, f.Purpose)
+
+	// Not seekable file can't be retried
+	attempts = 0
+	_, err = e.UploadFile(context.Background(), &UploadFileOptions{
+		File:     io.MultiReader(strings.NewReader(content)),
+		Filename: "train.jsonl",
+		Purpose:  FilePurposeFineTune,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestFiles(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "fine-tune", r.URL.Query().Get("purpose"))
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		assert.Equal(t, "file-1", r.URL.Query().Get("after"))
+		w.Write([]byte(`{"object":"list","data":[{"id":"file-2"},{"id":"file-3"}],"first_id":"file-2","last_id":"file-3","has_more":true}`))
+	})
+	mux.HandleFunc("/files/file-2", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"id":"file-2","object":"file","bytes":5,"filename":"train.jsonl","purpose":"fine-tune"}`))
+		case http.MethodDelete:
+			w.Write([]byte(`{"id":"file-2","object":"file","deleted":true}`))
+		}
+	})
+	mux.HandleFunc("/files/file-2/content", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+	list, err := e.ListFiles(ctx, &ListFilesOptions{Purpose: FilePurposeFineTune, Limit: 2, After: "file-1"})
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 2)
+	assert.True(t, list.HasMore)
+	assert.Equal(t, "file-3", list.LastId)
+
+	f, err := e.RetrieveFile(ctx, "file-2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), f.Bytes)
+
+	content, err := e.RetrieveFileContent(ctx, "file-2")
+	assert.NoError(t, err)
+	b, err := io.ReadAll(content)
+	assert.NoError(t, err)
+	assert.NoError(t, content.Close())
+	assert.Equal(t, "hello", string(b))
+
+	deleted, err := e.DeleteFile(ctx, "file-2")
+	assert.NoError(t, err)
+	assert.True(t, deleted.Deleted)
+
+	_, err = e.RetrieveFile(ctx, "")
+	assert.Error(t, err)
+}
+
+func TestUploadFileNotSent(t *testing.T) {
+	const content = `{"messages":[{"role":"user","content":"Hello"}]}` + "\n"
+	// The body of the file is larger than the pipe buffer, so its writer blocks until it's read or closed
+	upload := func(e *Engine, ctx context.Context) error {
+		_, err := e.UploadFile(ctx, &UploadFileOptions{
+			File:     strings.NewReader(strings.Repeat(content, 1000)),
+			Filename: "train.jsonl",
+			Purpose:  FilePurposeFineTune,
+		})
+		return err
+	}
+	respond := func(statusCode int) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: statusCode,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(`{"id":"file-abc123"}`)),
+					Request:    req,
+				}, nil
+			}
+		}
+	}
+	before := runtime.NumGoroutine()
+
+	// Short-circuited by middleware
+	e := NewWithOptions("test", WithMiddleware(respond(http.StatusOK)))
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, upload(e, context.Background()))
+	}
+
+	// Blocked by rate limiter
+	e = NewWithOptions("test", WithMiddleware(respond(http.StatusOK)),
+		WithRateLimiter(NewRateLimiter(RateLimit{RequestsPerMinute: 1, TokensPerMinute: 1000})))
+	assert.NoError(t, upload(e, context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	assert.ErrorIs(t, upload(e, ctx), context.DeadlineExceeded)
+	cancel()
+
+	// Cancelled during backoff
+	policy := testRetryPolicy
+	policy.BaseDelay, policy.MaxDelay = time.Minute, 0
+	e = NewWithOptions("test", WithMiddleware(respond(http.StatusServiceUnavailable)), WithRetryPolicy(policy))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	assert.ErrorIs(t, upload(e, ctx), context.DeadlineExceeded)
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before, "writers of the file body must stop")
+}
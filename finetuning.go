@@ -0,0 +1,447 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// FineTuningJobStatus represents the current status of the fine-tuning job.
+type FineTuningJobStatus string
+
+const (
+	FineTuningJobStatusValidatingFiles FineTuningJobStatus = "validating_files"
+	FineTuningJobStatusQueued          FineTuningJobStatus = "queued"
+	FineTuningJobStatusRunning         FineTuningJobStatus = "running"
+	FineTuningJobStatusSucceeded       FineTuningJobStatus = "succeeded"
+	FineTuningJobStatusFailed          FineTuningJobStatus = "failed"
+	FineTuningJobStatusCancelled       FineTuningJobStatus = "cancelled"
+)
+
+// Terminal reports whether the job is finished and its status won't change anymore.
+func (s FineTuningJobStatus) Terminal() bool {
+	return s == FineTuningJobStatusSucceeded || s == FineTuningJobStatusFailed || s == FineTuningJobStatusCancelled
+}
+
+// Hyperparameters used for the fine-tuning job.
+// Zero values mean auto, so OpenAI picks the value based on the dataset.
+type Hyperparameters struct {
+	// The number of epochs to train the model for.
+	// An epoch refers to one full cycle through the training dataset.
+	NEpochs int `json:"n_epochs,omitempty" binding:"omitempty,min=1,max=50"`
+	// Number of examples in each batch.
+	// A larger batch size means that model parameters are updated less frequently, but with lower variance.
+	BatchSize int `json:"batch_size,omitempty" binding:"omitempty,min=1,max=256"`
+	// Scaling factor for the learning rate.
+	// A smaller learning rate may be useful to avoid overfitting.
+	LearningRateMultiplier float64 `json:"learning_rate_multiplier,omitempty" binding:"omitempty,gt=0"`
+}
+
+// UnmarshalJSON decodes hyperparameters, where "auto" values are decoded as zero.
+func (h *Hyperparameters) UnmarshalJSON(data []byte) error {
+	var v struct {
+		NEpochs                json.RawMessage `json:"n_epochs"`
+		BatchSize              json.RawMessage `json:"batch_size"`
+		LearningRateMultiplier json.RawMessage `json:"learning_rate_multiplier"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	h.NEpochs = int(autoNumber(v.NEpochs))
+	h.BatchSize = int(autoNumber(v.BatchSize))
+	h.LearningRateMultiplier = autoNumber(v.LearningRateMultiplier)
+	return nil
+}
+
+// autoNumber returns the number from JSON or zero if it's "auto" or null.
+func autoNumber(raw json.RawMessage) float64 {
+	var n float64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0
+	}
+	return n
+}
+
+type FineTuningJob struct {
+	Id     string `json:"id"`
+	Object string `json:"object"`
+	// The Unix timestamp (in seconds) for when the fine-tuning job was created.
+	CreatedAt int64 `json:"created_at"`
+	// The Unix timestamp (in seconds) for when the fine-tuning job was finished.
+	// Zero if the job is still running.
+	FinishedAt int64 `json:"finished_at"`
+	// The base model that is being fine-tuned.
+	Model Model `json:"model"`
+	// The name of the fine-tuned model that is being created.
+	// Empty if the job is still running.
+	FineTunedModel Model               `json:"fine_tuned_model"`
+	OrganizationId string              `json:"organization_id"`
+	Status         FineTuningJobStatus `json:"status"`
+	// The hyperparameters used for the fine-tuning job.
+	Hyperparameters Hyperparameters `json:"hyperparameters"`
+	TrainingFile    string          `json:"training_file"`
+	ValidationFile  string          `json:"validation_file"`
+	// The compiled results file ID(s) for the fine-tuning job.
+	// Results can be retrieved with RetrieveFileContent.
+	ResultFiles []string `json:"result_files"`
+	// The total number of billable tokens processed by this fine-tuning job.
+	TrainedTokens int `json:"trained_tokens"`
+	// The seed used for the fine-tuning job.
+	Seed int `json:"seed"`
+	// The Unix timestamp (in seconds) for when the fine-tuning job is estimated to finish.
+	EstimatedFinish int64 `json:"estimated_finish"`
+	// The reason why the job failed, present only for failed jobs.
+	Error *FineTuningJobError `json:"error"`
+}
+
+type FineTuningJobError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param"`
+}
+
+type CreateFineTuningJobOptions struct {
+	// The name of the model to fine-tune.
+	Model Model `json:"model" binding:"required"`
+	// The ID of an uploaded file that contains training data.
+	TrainingFile string `json:"training_file" binding:"required"`
+	// The ID of an uploaded file that contains validation data.
+	ValidationFile string `json:"validation_file,omitempty"`
+	// The hyperparameters used for the fine-tuning job.
+	Hyperparameters *Hyperparameters `json:"hyperparameters,omitempty"`
+	// A string of up to 64 characters that will be added to your fine-tuned model name.
+	Suffix string `json:"suffix,omitempty" binding:"omitempty,max=64"`
+	// The seed controls the reproducibility of the job.
+	Seed int `json:"seed,omitempty"`
+}
+
+// CreateFineTuningJob creates a fine-tuning job which begins the process of creating
+// a new model from a given dataset.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/create
+func (e *Engine) CreateFineTuningJob(ctx context.Context, opts *CreateFineTuningJobOptions) (*FineTuningJob, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	if err := checkModel(opts.Model, EndpointFineTuning); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/fine_tuning/jobs")
+	r, err := marshalJson(opts)
+	if err != nil {
+		return nil, err
+	}
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp FineTuningJob
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// ListOptions are used for cursor-based pagination of lists.
+type ListOptions struct {
+	// Identifier for the last object from the previous page.
+	After string
+	// Number of objects to retrieve.
+	Limit int `binding:"omitempty,min=1,max=100"`
+}
+
+func (o *ListOptions) encode(uri string) string {
+	if o == nil {
+		return uri
+	}
+	query := url.Values{}
+	if o.After != "" {
+		query.Set("after", o.After)
+	}
+	if o.Limit != 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return withQuery(uri, query)
+}
+
+type ListFineTuningJobsResponse struct {
+	Object  string          `json:"object"`
+	Data    []FineTuningJob `json:"data"`
+	HasMore bool            `json:"has_more"`
+}
+
+// ListFineTuningJobs lists organization's fine-tuning jobs. Options are optional.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/list
+func (e *Engine) ListFineTuningJobs(ctx context.Context, opts *ListOptions) (*ListFineTuningJobsResponse, error) {
+	if opts != nil {
+		if err := e.validate.StructCtx(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	uri := opts.encode(e.apiURL("/fine_tuning/jobs"))
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp ListFineTuningJobsResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// RetrieveFineTuningJob gets info about a fine-tuning job.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/retrieve
+func (e *Engine) RetrieveFineTuningJob(ctx context.Context, jobId string) (*FineTuningJob, error) {
+	return e.fineTuningJobReq(ctx, http.MethodGet, jobId, "")
+}
+
+// CancelFineTuningJob immediately cancels a fine-tuning job.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/cancel
+func (e *Engine) CancelFineTuningJob(ctx context.Context, jobId string) (*FineTuningJob, error) {
+	return e.fineTuningJobReq(ctx, http.MethodPost, jobId, "/cancel")
+}
+
+func (e *Engine) fineTuningJobReq(ctx context.Context, method string, jobId string, action string) (*FineTuningJob, error) {
+	if err := e.validate.VarCtx(ctx, jobId, "required"); err != nil {
+		return nil, err
+	}
+	uri := e.apiURL("/fine_tuning/jobs/" + url.PathEscape(jobId) + action)
+	req, err := e.newReq(ctx, method, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp FineTuningJob
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+type FineTuningEvent struct {
+	Id     string `json:"id"`
+	Object string `json:"object"`
+	// The Unix timestamp (in seconds) for when the event was created.
+	CreatedAt int64 `json:"created_at"`
+	// The log level of the event: info, warn or error.
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	// The type of the event: message or metrics.
+	Type string `json:"type"`
+	// Additional data of the event, e.g. training metrics.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type ListFineTuningEventsResponse struct {
+	Object string `json:"object"`
+	// Events are sorted from the newest to the oldest.
+	Data    []FineTuningEvent `json:"data"`
+	HasMore bool              `json:"has_more"`
+}
+
+// ListFineTuningEvents gets status updates for a fine-tuning job. Options are optional.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/list-events
+func (e *Engine) ListFineTuningEvents(ctx context.Context, jobId string, opts *ListOptions) (*ListFineTuningEventsResponse, error) {
+	if err := e.validate.VarCtx(ctx, jobId, "required"); err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		if err := e.validate.StructCtx(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	uri := opts.encode(e.apiURL("/fine_tuning/jobs/" + url.PathEscape(jobId) + "/events"))
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp ListFineTuningEventsResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
+
+// defaultFollowInterval is the default interval between polls of the fine-tuning job.
+const defaultFollowInterval = 10 * time.Second
+
+// FineTuningEventStream is a stream of fine-tuning job events returned by FollowFineTuningEvents.
+type FineTuningEventStream struct {
+	ctx      context.Context
+	e        *Engine
+	jobId    string
+	interval time.Duration
+	polled   bool
+	done     bool
+	pending  []FineTuningEvent
+	seen     map[string]bool
+}
+
+// FollowFineTuningEvents polls the fine-tuning job every interval and returns the stream of its events
+// in chronological order, starting from the oldest one. The stream is finished when the job reaches
+// a terminal state and all its events are received.
+//
+// If interval is zero, the job is polled every 10 seconds.
+func (e *Engine) FollowFineTuningEvents(ctx context.Context, jobId string, interval time.Duration) *FineTuningEventStream {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if interval <= 0 {
+		interval = defaultFollowInterval
+	}
+	return &FineTuningEventStream{
+		ctx:      ctx,
+		e:        e,
+		jobId:    jobId,
+		interval: interval,
+		seen:     make(map[string]bool),
+	}
+}
+
+// Recv returns the next event of the job, waiting for it if needed.
+// It returns io.EOF when the job is finished and all events are received.
+func (s *FineTuningEventStream) Recv() (*FineTuningEvent, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return nil, io.EOF
+		}
+		if s.polled {
+			timer := time.NewTimer(s.interval)
+			select {
+			case <-s.ctx.Done():
+				timer.Stop()
+				return nil, s.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := s.poll(); err != nil {
+			return nil, err
+		}
+	}
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return &event, nil
+}
+
+// poll retrieves the job status and events which were not seen yet.
+// Status is retrieved first, so events emitted before the job was finished are not lost.
+func (s *FineTuningEventStream) poll() error {
+	s.polled = true
+	job, err := s.e.RetrieveFineTuningJob(s.ctx, s.jobId)
+	if err != nil {
+		return err
+	}
+	var events []FineTuningEvent
+	opts := &ListOptions{Limit: 100}
+	for {
+		resp, err := s.e.ListFineTuningEvents(s.ctx, s.jobId, opts)
+		if err != nil {
+			return err
+		}
+		reachedSeen := false
+		for _, event := range resp.Data {
+			if s.seen[event.Id] {
+				reachedSeen = true
+				break
+			}
+			events = append(events, event)
+		}
+		if reachedSeen || !resp.HasMore || len(resp.Data) == 0 {
+			break
+		}
+		opts.After = resp.Data[len(resp.Data)-1].Id
+	}
+	// Events are listed from the newest to the oldest
+	for i := len(events) - 1; i >= 0; i-- {
+		s.seen[events[i].Id] = true
+		s.pending = append(s.pending, events[i])
+	}
+	s.done = job.Status.Terminal()
+	return nil
+}
+
+type FineTuningCheckpoint struct {
+	Id     string `json:"id"`
+	Object string `json:"object"`
+	// The Unix timestamp (in seconds) for when the checkpoint was created.
+	CreatedAt int64 `json:"created_at"`
+	// The name of the fine-tuned checkpoint model that is created.
+	FineTunedModelCheckpoint Model `json:"fine_tuned_model_checkpoint"`
+	// The step number that the checkpoint was created at.
+	StepNumber int `json:"step_number"`
+	// Metrics at the step number during the fine-tuning job.
+	Metrics struct {
+		Step                       float64 `json:"step"`
+		TrainLoss                  float64 `json:"train_loss"`
+		TrainMeanTokenAccuracy     float64 `json:"train_mean_token_accuracy"`
+		ValidLoss                  float64 `json:"valid_loss"`
+		ValidMeanTokenAccuracy     float64 `json:"valid_mean_token_accuracy"`
+		FullValidLoss              float64 `json:"full_valid_loss"`
+		FullValidMeanTokenAccuracy float64 `json:"full_valid_mean_token_accuracy"`
+	} `json:"metrics"`
+	// The name of the fine-tuning job that this checkpoint was created from.
+	FineTuningJobId string `json:"fine_tuning_job_id"`
+}
+
+type ListFineTuningCheckpointsResponse struct {
+	Object  string                 `json:"object"`
+	Data    []FineTuningCheckpoint `json:"data"`
+	FirstId string                 `json:"first_id"`
+	LastId  string                 `json:"last_id"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// ListFineTuningCheckpoints lists checkpoints for a fine-tuning job. Options are optional.
+//
+// Docs: https://platform.openai.com/docs/api-reference/fine-tuning/list-checkpoints
+func (e *Engine) ListFineTuningCheckpoints(ctx context.Context, jobId string, opts *ListOptions) (*ListFineTuningCheckpointsResponse, error) {
+	if err := e.validate.VarCtx(ctx, jobId, "required"); err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		if err := e.validate.StructCtx(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	uri := opts.encode(e.apiURL("/fine_tuning/jobs/" + url.PathEscape(jobId) + "/checkpoints"))
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doReq(req)
+	if err != nil {
+		return nil, err
+	}
+	var jsonResp ListFineTuningCheckpointsResponse
+	if err := unmarshal(resp, &jsonResp); err != nil {
+		return nil, err
+	}
+	return &jsonResp, nil
+}
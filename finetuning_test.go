@@ -0,0 +1,151 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFineTuningJobs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fine_tuning/jobs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "gpt-3.5-turbo", body["model"])
+			assert.Equal(t, "file-abc123", body["training_file"])
+			assert.Equal(t, map[string]interface{}{"n_epochs": float64(3)}, body["hyperparameters"])
+			w.Write([]byte(`{"id":"ftjob-1","object":"fine_tuning.job","model":"gpt-3.5-turbo","status":"queued",` +
+				`"hyperparameters":{"n_epochs":3,"batch_size":"auto","learning_rate_multiplier":"auto"},"finished_at":null,"fine_tuned_model":null}`))
+		case http.MethodGet:
+			assert.Equal(t, "ftjob-0", r.URL.Query().Get("after"))
+			assert.Equal(t, "1", r.URL.Query().Get("limit"))
+			w.Write([]byte(`{"object":"list","data":[{"id":"ftjob-1","status":"running"}],"has_more":true}`))
+		}
+	})
+	mux.HandleFunc("/fine_tuning/jobs/ftjob-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"ftjob-1","status":"succeeded","fine_tuned_model":"ft:gpt-3.5-turbo:org::abc",` +
+			`"hyperparameters":{"n_epochs":3,"batch_size":1,"learning_rate_multiplier":2}}`))
+	})
+	mux.HandleFunc("/fine_tuning/jobs/ftjob-1/cancel", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		w.Write([]byte(`{"id":"ftjob-1","status":"cancelled"}`))
+	})
+	mux.HandleFunc("/fine_tuning/jobs/ftjob-1/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"object":"list","data":[{"id":"ftckpt-1","step_number":100,"metrics":{"train_loss":0.5}}],"has_more":false}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+	job, err := e.CreateFineTuningJob(ctx, &CreateFineTuningJobOptions{
+		Model:           ModelGPT3Dot5Turbo,
+		TrainingFile:    "file-abc123",
+		Hyperparameters: &Hyperparameters{NEpochs: 3},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, FineTuningJobStatusQueued, job.Status)
+	assert.False(t, job.Status.Terminal())
+	assert.Equal(t, Hyperparameters{NEpochs: 3}, job.Hyperparameters)
+	assert.Equal(t, Model(""), job.FineTunedModel)
+
+	list, err := e.ListFineTuningJobs(ctx, &ListOptions{After: "ftjob-0", Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 1)
+	assert.True(t, list.HasMore)
+
+	job, err = e.RetrieveFineTuningJob(ctx, "ftjob-1")
+	assert.NoError(t, err)
+	assert.True(t, job.Status.Terminal())
+	assert.Equal(t, Hyperparameters{NEpochs: 3, BatchSize: 1, LearningRateMultiplier: 2}, job.Hyperparameters)
+
+	job, err = e.CancelFineTuningJob(ctx, "ftjob-1")
+	assert.NoError(t, err)
+	assert.Equal(t, FineTuningJobStatusCancelled, job.Status)
+
+	checkpoints, err := e.ListFineTuningCheckpoints(ctx, "ftjob-1", nil)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints.Data, 1)
+	assert.Equal(t, 100, checkpoints.Data[0].StepNumber)
+	assert.Equal(t, 0.5, checkpoints.Data[0].Metrics.TrainLoss)
+
+	_, err = e.RetrieveFineTuningJob(ctx, "")
+	assert.Error(t, err)
+	_, err = e.CreateFineTuningJob(ctx, &CreateFineTuningJobOptions{Model: ModelGPT3Dot5Turbo})
+	assert.Error(t, err, "training file is required")
+}
+
+func TestFollowFineTuningEvents(t *testing.T) {
+	// Events are listed from the newest to the oldest, as the API does
+	var (
+		polls  int
+		events = []string{`{"id":"ev-2","message":"Step 2"}`, `{"id":"ev-1","message":"Step 1"}`}
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fine_tuning/jobs/ftjob-1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			w.Write([]byte(`{"id":"ftjob-1","status":"running"}`))
+			return
+		}
+		events = append([]string{`{"id":"ev-3","message":"Job succeeded"}`}, events...)
+		w.Write([]byte(`{"id":"ftjob-1","status":"succeeded"}`))
+	})
+	mux.HandleFunc("/fine_tuning/jobs/ftjob-1/events", func(w http.ResponseWriter, r *http.Request) {
+		// Serve one event per page
+		page := events
+		if after := r.URL.Query().Get("after"); after != "" {
+			for i := range events {
+				var ev FineTuningEvent
+				json.Unmarshal([]byte(events[i]), &ev)
+				if ev.Id == after {
+					page = events[i+1:]
+				}
+			}
+		}
+		hasMore := len(page) > 1
+		if len(page) > 1 {
+			page = page[:1]
+		}
+		data := "[]"
+		if len(page) == 1 {
+			data = "[" + page[0] + "]"
+		}
+		w.Write([]byte(`{"object":"list","data":` + data + `,"has_more":` + strconv.FormatBool(hasMore) + `}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	stream := e.FollowFineTuningEvents(context.Background(), "ftjob-1", time.Millisecond)
+	var messages []string
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		messages = append(messages, ev.Message)
+	}
+	assert.Equal(t, []string{"Step 1", "Step 2", "Job succeeded"}, messages)
+	assert.Equal(t, 3, polls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.FollowFineTuningEvents(ctx, "ftjob-1", time.Millisecond).Recv()
+	assert.ErrorIs(t, err, context.Canceled)
+}
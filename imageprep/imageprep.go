@@ -0,0 +1,343 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package imageprep prepares images for the image edit and variation endpoints,
+// which accept only square PNG files less than 4MB.
+//
+// JPEG and PNG images of any size are converted to square RGBA PNG images of one of
+// the openai.Size* sizes, and masks are built from rectangles and polygons.
+// Only the standard library is used. EXIF orientation of JPEG images is not applied.
+package imageprep
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // register decoder
+	"image/png"
+	"io"
+	"math"
+
+	openai "github.com/0x9ef/openai-go"
+)
+
+// ErrTooLarge is returned when the image can't be encoded within the size limit.
+var ErrTooLarge = errors.New("image can't be encoded within the size limit")
+
+// minSide is the smallest side the image is downscaled to when it doesn't fit the size limit.
+const minSide = 256
+
+// Fit defines how the image is made square.
+type Fit int
+
+const (
+	// FitPad scales the whole image into the square and fills the rest with transparent pixels.
+	// Without a mask, the transparent padding is the area edited by the API, so the image is extended.
+	FitPad Fit = iota
+	// FitCrop scales the image to cover the square and crops the center.
+	FitCrop
+)
+
+// Options configure the preparation of the image.
+type Options struct {
+	// Size of the prepared image, one of openai.Size256, openai.Size512 or openai.Size1024.
+	//
+	// Default: openai.Size1024
+	Size openai.Size
+	// How the image is made square.
+	//
+	// Default: FitPad
+	Fit Fit
+	// The maximum size of the encoded PNG file. If the image is larger, it's downscaled.
+	//
+	// Default: openai.MaxImageFileSize
+	MaxBytes int
+}
+
+func (o *Options) side() (int, error) {
+	size := openai.Size1024
+	if o != nil && o.Size != "" {
+		size = o.Size
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(string(size), "%dx%d", &width, &height); err != nil || width != height || width <= 0 {
+		return 0, fmt.Errorf("invalid size %q, must be square", size)
+	}
+	return width, nil
+}
+
+func (o *Options) maxBytes() int {
+	if o != nil && o.MaxBytes > 0 {
+		return o.MaxBytes
+	}
+	return openai.MaxImageFileSize
+}
+
+// Image is the prepared square image.
+type Image struct {
+	*image.RGBA
+	// origin, scale and offset map points of the source image to the prepared one.
+	origin image.Point
+	scale  float64
+	offset image.Point
+}
+
+// Point maps the point of the source image to the prepared image.
+func (img *Image) Point(p image.Point) image.Point {
+	return image.Point{
+		X: int(float64(p.X-img.origin.X)*img.scale+0.5) + img.offset.X,
+		Y: int(float64(p.Y-img.origin.Y)*img.scale+0.5) + img.offset.Y,
+	}
+}
+
+// Rect maps the rectangle of the source image to the prepared image.
+func (img *Image) Rect(r image.Rectangle) image.Rectangle {
+	return image.Rectangle{Min: img.Point(r.Min), Max: img.Point(r.Max)}
+}
+
+// Side returns the length of the image side.
+func (img *Image) Side() int {
+	return img.Bounds().Dx()
+}
+
+// Prepare decodes JPEG or PNG image and converts it to the square RGBA image.
+func Prepare(r io.Reader, opts *Options) (*Image, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	return PrepareImage(src, opts)
+}
+
+// PrepareImage converts the image to the square RGBA image.
+func PrepareImage(src image.Image, opts *Options) (*Image, error) {
+	side, err := opts.side()
+	if err != nil {
+		return nil, err
+	}
+	fit := FitPad
+	if opts != nil {
+		fit = opts.Fit
+	}
+	b := src.Bounds()
+	if b.Empty() {
+		return nil, errors.New("image is empty")
+	}
+	w, h := float64(b.Dx()), float64(b.Dy())
+	// Padded image fits the longer side into the square, cropped one covers it with the shorter side
+	long, short := math.Max(w, h), math.Min(w, h)
+	scale := float64(side) / long
+	if fit == FitCrop {
+		scale = float64(side) / short
+	}
+	scaledW, scaledH := int(w*scale+0.5), int(h*scale+0.5)
+	scaled := resize(toRGBA(src), scaledW, scaledH)
+	offset := image.Point{X: (side - scaledW) / 2, Y: (side - scaledH) / 2}
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Src)
+	return &Image{RGBA: dst, origin: b.Min, scale: scale, offset: offset}, nil
+}
+
+// EncodePNG encodes the image to PNG not larger than maxBytes.
+// If the image is too large even with the best compression, it's downscaled by half
+// until it fits, but not below 256x256, so it stays valid for the API.
+func EncodePNG(img image.Image, maxBytes int) ([]byte, error) {
+	if maxBytes <= 0 {
+		maxBytes = openai.MaxImageFileSize
+	}
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	for {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("encode png: %w", err)
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), nil
+		}
+		b := img.Bounds()
+		if b.Dx()/2 < minSide || b.Dy()/2 < minSide {
+			return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, buf.Len(), maxBytes)
+		}
+		img = resize(toRGBA(img), b.Dx()/2, b.Dy()/2)
+	}
+}
+
+// EditOptions prepares the image and the optional mask for ImageEdit.
+// The mask is resized to the image if needed. Size of the returned options is set to the size
+// of the encoded image, which is opts.Size unless the image is downscaled to fit opts.MaxBytes.
+func EditOptions(r io.Reader, mask *Mask, prompt string, opts *Options) (*openai.ImageEditOptions, error) {
+	img, err := Prepare(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	imgData, err := EncodePNG(img, opts.maxBytes())
+	if err != nil {
+		return nil, err
+	}
+	// The image may be downscaled by EncodePNG, so the size and the mask must match the encoded image
+	cfg, err := png.DecodeConfig(bytes.NewReader(imgData))
+	if err != nil {
+		return nil, fmt.Errorf("decode prepared image: %w", err)
+	}
+	editOpts := &openai.ImageEditOptions{
+		Image:  bytes.NewReader(imgData),
+		Prompt: prompt,
+		Size:   imageSize(cfg),
+	}
+	if mask != nil {
+		m := image.Image(mask.Image())
+		if m.Bounds().Dx() != cfg.Width || m.Bounds().Dy() != cfg.Height {
+			m = resizeNearest(m, cfg.Width, cfg.Height)
+		}
+		maskData, err := EncodePNG(m, opts.maxBytes())
+		if err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+		editOpts.Mask = bytes.NewReader(maskData)
+	}
+	return editOpts, nil
+}
+
+// VariationOptions prepares the image for ImageVariation.
+// Size of the returned options is set to the size of the encoded image,
+// which is opts.Size unless the image is downscaled to fit opts.MaxBytes.
+func VariationOptions(r io.Reader, opts *Options) (*openai.ImageVariationOptions, error) {
+	img, err := Prepare(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := EncodePNG(img, opts.maxBytes())
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode prepared image: %w", err)
+	}
+	return &openai.ImageVariationOptions{
+		Image: bytes.NewReader(data),
+		Size:  imageSize(cfg),
+	}, nil
+}
+
+func imageSize(cfg image.Config) openai.Size {
+	return openai.Size(fmt.Sprintf("%dx%d", cfg.Width, cfg.Height))
+}
+
+// toRGBA returns the image as *image.RGBA with bounds starting at zero point.
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// resizeNearest scales the image using nearest-neighbour interpolation, so no new colors are introduced.
+// It's used for masks, whose pixels must stay fully transparent or opaque.
+func resizeNearest(src image.Image, width, height int) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Sample at the pixel center
+		sy := b.Min.Y + (2*y+1)*b.Dy()/(2*height)
+		for x := 0; x < width; x++ {
+			sx := b.Min.X + (2*x+1)*b.Dx()/(2*width)
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// resize scales the image using bilinear interpolation of premultiplied colors.
+// When downscaling more than twice, the image is halved with box filter first to avoid aliasing.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	if width <= 0 {
+		width = 1
+	}
+	if height <= 0 {
+		height = 1
+	}
+	for src.Bounds().Dx() >= 2*width && src.Bounds().Dy() >= 2*height {
+		src = halve(src)
+	}
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == width && sh == height {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sx := float64(sw) / float64(width)
+	sy := float64(sh) / float64(height)
+	for y := 0; y < height; y++ {
+		fy := (float64(y)+0.5)*sy - 0.5
+		y0, ty := split(fy, sh)
+		y1 := clamp(y0+1, sh)
+		for x := 0; x < width; x++ {
+			fx := (float64(x)+0.5)*sx - 0.5
+			x0, tx := split(fx, sw)
+			x1 := clamp(x0+1, sw)
+			p00 := src.RGBAAt(x0, y0)
+			p10 := src.RGBAAt(x1, y0)
+			p01 := src.RGBAAt(x0, y1)
+			p11 := src.RGBAAt(x1, y1)
+			dst.SetRGBA(x, y, color.RGBA{
+				R: lerp2(p00.R, p10.R, p01.R, p11.R, tx, ty),
+				G: lerp2(p00.G, p10.G, p01.G, p11.G, tx, ty),
+				B: lerp2(p00.B, p10.B, p01.B, p11.B, tx, ty),
+				A: lerp2(p00.A, p10.A, p01.A, p11.A, tx, ty),
+			})
+		}
+	}
+	return dst
+}
+
+// halve downscales the image by half averaging 2x2 blocks.
+func halve(src *image.RGBA) *image.RGBA {
+	w, h := src.Bounds().Dx()/2, src.Bounds().Dy()/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, b, a int
+			for _, p := range [4]color.RGBA{
+				src.RGBAAt(2*x, 2*y), src.RGBAAt(2*x+1, 2*y),
+				src.RGBAAt(2*x, 2*y+1), src.RGBAAt(2*x+1, 2*y+1),
+			} {
+				r, g, b, a = r+int(p.R), g+int(p.G), b+int(p.B), a+int(p.A)
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8((r + 2) / 4), G: uint8((g + 2) / 4), B: uint8((b + 2) / 4), A: uint8((a + 2) / 4)})
+		}
+	}
+	return dst
+}
+
+// split returns the integer part of f clamped to [0, n) and the fraction.
+func split(f float64, n int) (int, float64) {
+	if f < 0 {
+		return 0, 0
+	}
+	i := int(f)
+	if i >= n-1 {
+		return n - 1, 0
+	}
+	return i, f - float64(i)
+}
+
+func clamp(i, n int) int {
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func lerp2(p00, p10, p01, p11 uint8, tx, ty float64) uint8 {
+	top := float64(p00)*(1-tx) + float64(p10)*tx
+	bottom := float64(p01)*(1-tx) + float64(p11)*tx
+	return uint8(top*(1-ty) + bottom*ty + 0.5)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package imageprep
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestPrepare(t *testing.T) {
+	img, err := Prepare(bytes.NewReader(testJPEG(t, 200, 100)), &Options{Size: openai.Size256})
+	assert.NoError(t, err)
+	assert.Equal(t, 256, img.Side())
+	assert.Equal(t, uint8(0), img.RGBAAt(128, 10).A, "padding must be transparent")
+	assert.Equal(t, uint8(0xff), img.RGBAAt(128, 128).A)
+	assert.InDelta(t, 200, img.RGBAAt(128, 128).R, 2)
+	assert.Equal(t, image.Pt(0, 64), img.Point(image.Pt(0, 0)))
+	assert.Equal(t, image.Rect(0, 64, 256, 192), img.Rect(image.Rect(0, 0, 200, 100)))
+
+	img, err = Prepare(bytes.NewReader(testJPEG(t, 100, 200)), &Options{Size: openai.Size512, Fit: FitCrop})
+	assert.NoError(t, err)
+	assert.Equal(t, 512, img.Side())
+	assert.Equal(t, uint8(0xff), img.RGBAAt(0, 0).A, "cropped image has no padding")
+	assert.Equal(t, image.Pt(256, 256), img.Point(image.Pt(50, 100)))
+
+	// Default size
+	img, err = Prepare(bytes.NewReader(testJPEG(t, 10, 10)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, img.Side())
+
+	_, err = Prepare(bytes.NewReader(testJPEG(t, 10, 10)), &Options{Size: openai.Size1792x1024})
+	assert.Error(t, err)
+	_, err = Prepare(bytes.NewReader([]byte("not an image")), nil)
+	assert.Error(t, err)
+}
+
+func TestEncodePNG(t *testing.T) {
+	// Noise doesn't compress, so the image must be downscaled
+	noise := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	rand.New(rand.NewSource(1)).Read(noise.Pix)
+	for i := 3; i < len(noise.Pix); i += 4 {
+		noise.Pix[i] = 0xff
+	}
+	data, err := EncodePNG(noise, 300<<10)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 300<<10)
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 256, cfg.Width)
+	assert.Equal(t, 256, cfg.Height)
+
+	_, err = EncodePNG(noise, 1000)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestMask(t *testing.T) {
+	m := NewMask(100).
+		Rect(image.Rect(10, 10, 20, 20)).
+		Polygon(image.Pt(50, 50), image.Pt(90, 50), image.Pt(50, 90))
+	mask := m.Image()
+	assert.Equal(t, uint8(0xff), mask.NRGBAAt(0, 0).A)
+	assert.Equal(t, uint8(0), mask.NRGBAAt(15, 15).A)
+	assert.Equal(t, uint8(0xff), mask.NRGBAAt(20, 20).A)
+	assert.Equal(t, uint8(0), mask.NRGBAAt(55, 55).A)
+	assert.Equal(t, uint8(0), mask.NRGBAAt(50, 50).A)
+	assert.Equal(t, uint8(0xff), mask.NRGBAAt(85, 85).A, "outside of the triangle")
+	assert.Equal(t, uint8(0xff), mask.NRGBAAt(90, 50).A)
+
+	img, err := Prepare(bytes.NewReader(testJPEG(t, 100, 100)), &Options{Size: openai.Size256})
+	assert.NoError(t, err)
+	NewMask(img.Side()).Rect(img.Rect(image.Rect(0, 0, 50, 50))).Apply(img)
+	assert.Equal(t, uint8(0), img.RGBAAt(10, 10).A)
+	assert.Equal(t, uint8(0xff), img.RGBAAt(200, 200).A)
+}
+
+func TestEditOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(10<<20))
+		assert.Equal(t, string(openai.Size512), r.FormValue("size"))
+		fields := []string{"image"}
+		if r.URL.Path == "/images/edits" {
+			fields = append(fields, "mask")
+		}
+		for _, field := range fields {
+			f, _, err := r.FormFile(field)
+			if assert.NoError(t, err, field) {
+				cfg, err := png.DecodeConfig(f)
+				assert.NoError(t, err)
+				assert.Equal(t, 512, cfg.Width)
+				assert.Equal(t, 512, cfg.Height)
+			}
+		}
+		w.Write([]byte(`{"created":1,"data":[{"url":"https://example.com/1.png"}]}`))
+	}))
+	defer srv.Close()
+	e := openai.NewWithOptions("test", openai.WithBaseURL(srv.URL))
+
+	// The mask is resized to the prepared image
+	mask := NewMask(100).Rect(image.Rect(0, 0, 50, 50))
+	opts, err := EditOptions(bytes.NewReader(testJPEG(t, 640, 480)), mask, "Add a hat", &Options{Size: openai.Size512})
+	assert.NoError(t, err)
+	_, err = e.ImageEdit(context.Background(), opts)
+	assert.NoError(t, err)
+
+	varOpts, err := VariationOptions(bytes.NewReader(testJPEG(t, 480, 640)), &Options{Size: openai.Size512, Fit: FitCrop})
+	assert.NoError(t, err)
+	assert.Equal(t, openai.Size512, varOpts.Size)
+	_, err = e.ImageVariation(context.Background(), varOpts)
+	assert.NoError(t, err)
+}
+
+func TestEditOptionsDownscaled(t *testing.T) {
+	// Noise doesn't compress, so the image is downscaled to fit MaxBytes
+	noise := image.NewRGBA(image.Rect(0, 0, 512, 512))
+	rand.New(rand.NewSource(1)).Read(noise.Pix)
+	for i := 3; i < len(noise.Pix); i += 4 {
+		noise.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, noise))
+	opts := &Options{Size: openai.Size512, MaxBytes: 300 << 10}
+
+	// Diagonal edge of the polygon is resized without partially transparent pixels
+	mask := NewMask(100).Polygon(image.Pt(0, 0), image.Pt(100, 0), image.Pt(0, 100))
+	editOpts, err := EditOptions(bytes.NewReader(buf.Bytes()), mask, "Add a hat", opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, openai.Size256, editOpts.Size)
+	m, err := png.Decode(editOpts.Mask)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 256, m.Bounds().Dx())
+	alphas := make(map[uint8]bool)
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			alphas[color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA).A] = true
+		}
+	}
+	assert.Equal(t, map[uint8]bool{0: true, 0xff: true}, alphas)
+
+	varOpts, err := VariationOptions(bytes.NewReader(buf.Bytes()), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, openai.Size256, varOpts.Size)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package imageprep
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// Mask marks areas of the image which should be edited.
+// Areas added by Rect and Polygon are fully transparent, the rest of the mask is opaque,
+// so the API edits only the added areas.
+type Mask struct {
+	img *image.NRGBA
+}
+
+// NewMask creates the opaque square mask with the given side.
+// Use Image.Side to create the mask matching the prepared image.
+func NewMask(side int) *Mask {
+	img := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{A: 0xff}), image.Point{}, draw.Src)
+	return &Mask{img: img}
+}
+
+// Rect adds the rectangle to the edited area.
+// Use Image.Rect to map the rectangle of the source image.
+func (m *Mask) Rect(r image.Rectangle) *Mask {
+	draw.Draw(m.img, r.Canon(), image.Transparent, image.Point{}, draw.Src)
+	return m
+}
+
+// Polygon adds the polygon to the edited area. The polygon is closed automatically
+// and filled using the even-odd rule. Use Image.Point to map points of the source image.
+func (m *Mask) Polygon(points ...image.Point) *Mask {
+	if len(points) < 3 {
+		return m
+	}
+	b := m.img.Bounds()
+	var xs []float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		// Sample at the pixel center
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i, p := range points {
+			q := points[(i+1)%len(points)]
+			y0, y1 := float64(p.Y), float64(q.Y)
+			if (y0 <= cy) == (y1 <= cy) {
+				continue
+			}
+			x := float64(p.X) + (cy-y0)/(y1-y0)*float64(q.X-p.X)
+			xs = append(xs, x)
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			// Pixels with centers in [xs[i], xs[i+1])
+			start := int(math.Ceil(xs[i] - 0.5))
+			end := int(math.Ceil(xs[i+1] - 0.5))
+			if start < b.Min.X {
+				start = b.Min.X
+			}
+			if end > b.Max.X {
+				end = b.Max.X
+			}
+			for x := start; x < end; x++ {
+				m.img.SetNRGBA(x, y, color.NRGBA{})
+			}
+		}
+	}
+	return m
+}
+
+// Image returns the mask image.
+func (m *Mask) Image() *image.NRGBA {
+	return m.img
+}
+
+// Apply makes the edited area of the image transparent, so the image can be edited without a separate mask.
+func (m *Mask) Apply(img *Image) {
+	b := img.Bounds().Intersect(m.img.Bounds())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if m.img.NRGBAAt(x, y).A == 0 {
+				img.SetRGBA(x, y, color.RGBA{})
+			}
+		}
+	}
+}
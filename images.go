@@ -4,9 +4,21 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register decoder
+	_ "image/jpeg" // register decoder
+	"image/png"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"net/url"
+	"net/textproto"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -15,12 +27,54 @@ import (
 type Size string
 
 const (
-	Size256    = "256x256"
-	Size512    = "512x512"
-	Size1024   = "1024x1024"
-	SizeSmall  = Size256
-	SizeMedium = Size512
-	SizeBig    = Size1024
+	Size256    Size = "256x256"
+	Size512    Size = "512x512"
+	Size1024   Size = "1024x1024"
+	SizeSmall       = Size256
+	SizeMedium      = Size512
+	SizeBig         = Size1024
+	// Landscape and portrait sizes of DALL·E 3.
+	Size1792x1024 Size = "1792x1024"
+	Size1024x1792 Size = "1024x1792"
+	// Landscape and portrait sizes of GPT Image.
+	Size1536x1024 Size = "1536x1024"
+	Size1024x1536 Size = "1024x1536"
+	// SizeAuto lets GPT Image choose the size.
+	SizeAuto Size = "auto"
+)
+
+// Quality of the generated image.
+const (
+	// QualityStandard and QualityHD are supported by DALL·E 3.
+	QualityStandard = "standard"
+	QualityHD       = "hd"
+	// QualityLow, QualityMedium, QualityHigh and QualityAuto are supported by GPT Image.
+	QualityLow    = "low"
+	QualityMedium = "medium"
+	QualityHigh   = "high"
+	QualityAuto   = "auto"
+)
+
+// Style of the image generated by DALL·E 3.
+const (
+	// StyleVivid leans towards generating hyper-real and dramatic images.
+	StyleVivid = "vivid"
+	// StyleNatural produces more natural, less hyper-real looking images.
+	StyleNatural = "natural"
+)
+
+// Background of the image generated by GPT Image.
+const (
+	BackgroundTransparent = "transparent"
+	BackgroundOpaque      = "opaque"
+	BackgroundAuto        = "auto"
+)
+
+// Format of the image generated by GPT Image.
+const (
+	OutputFormatPNG  = "png"
+	OutputFormatJPEG = "jpeg"
+	OutputFormatWEBP = "webp"
 )
 
 // ResponseFormat represents image format of response.
@@ -32,24 +86,328 @@ const (
 	ResponseFormatB64Json = "b64_json"
 )
 
+// ErrUnsupportedImageOption is returned when the image option is not supported by the model.
+var ErrUnsupportedImageOption = errors.New("image option is not supported by the model")
+
 type ImageCreateOptions struct {
+	// The model to use for image generation.
+	//
+	// Default: dall-e-2
+	Model Model `json:"model,omitempty"`
+	// A text description of the desired image(s). The maximum length is 1000 characters for dall-e-2,
+	// 4000 characters for dall-e-3 and 32000 characters for gpt-image-1.
 	Prompt string `json:"prompt" binding:"required"`
 	// The number of images to generate.
-	// Must be between 1 and 10.
+	// Must be between 1 and 10. For dall-e-3, only 1 is supported.
 	N int `json:"n,omitempty" binding:"omitempty,min=1,max=10"`
 	// The size of the generated images.
-	// Must be one of 256x256, 512x512, or 1024x1024.
-	Size string `json:"size,omitempty" binding:"oneof=256x256 512x512 1024x1024"`
+	// Must be one of 256x256, 512x512, or 1024x1024 for dall-e-2 (default 256x256),
+	// one of 1024x1024, 1792x1024, or 1024x1792 for dall-e-3,
+	// and one of 1024x1024, 1536x1024, 1024x1536 or auto for gpt-image-1.
+	Size Size `json:"size,omitempty"`
+	// The quality of the image that will be generated.
+	// Must be standard or hd for dall-e-3, and one of low, medium, high or auto for gpt-image-1.
+	Quality string `json:"quality,omitempty"`
+	// The style of the generated images. Must be one of vivid or natural.
+	// Only supported by dall-e-3.
+	Style string `json:"style,omitempty"`
 	// The format in which the generated images are returned.
-	// Must be one of url or b64_json
+	// Must be one of url or b64_json. Not supported by gpt-image-1, which always returns b64_json.
 	ResponseFormat string `json:"response_format,omitempty" binding:"omitempty,oneof=url b64_json"`
+	// The background of the generated images. Must be one of transparent, opaque or auto.
+	// Transparent background requires png or webp output format. Only supported by gpt-image-1.
+	Background string `json:"background,omitempty"`
+	// The format of the generated images. Must be one of png, jpeg or webp.
+	// Only supported by gpt-image-1.
+	OutputFormat string `json:"output_format,omitempty"`
+	// The compression level (0-100%) of the generated images.
+	// Only supported by gpt-image-1 with jpeg or webp output format.
+	OutputCompression int `json:"output_compression,omitempty" binding:"omitempty,min=0,max=100"`
+	// A unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
+	User string `json:"user,omitempty"`
+}
+
+// imageModel describes options supported by the image model.
+type imageModel struct {
+	maxPrompt      int
+	maxN           int
+	sizes          []Size
+	qualities      []string
+	styles         []string
+	responseFormat bool
+	backgrounds    []string
+	outputFormats  []string
+}
+
+var imageModels = map[Model]imageModel{
+	ModelDallE2: {
+		maxPrompt:      1000,
+		maxN:           10,
+		sizes:          []Size{Size256, Size512, Size1024},
+		qualities:      []string{QualityStandard},
+		responseFormat: true,
+	},
+	ModelDallE3: {
+		maxPrompt:      4000,
+		maxN:           1,
+		sizes:          []Size{Size1024, Size1792x1024, Size1024x1792},
+		qualities:      []string{QualityStandard, QualityHD},
+		styles:         []string{StyleVivid, StyleNatural},
+		responseFormat: true,
+	},
+	ModelGPTImage1: {
+		maxPrompt:     32000,
+		maxN:          10,
+		sizes:         []Size{Size1024, Size1536x1024, Size1024x1536, SizeAuto},
+		qualities:     []string{QualityLow, QualityMedium, QualityHigh, QualityAuto},
+		backgrounds:   []string{BackgroundTransparent, BackgroundOpaque, BackgroundAuto},
+		outputFormats: []string{OutputFormatPNG, OutputFormatJPEG, OutputFormatWEBP},
+	},
+}
+
+// imageModelFor returns options supported by the model, which is dall-e-2 if not set.
+func imageModelFor(model Model) (Model, imageModel, bool) {
+	if model == "" {
+		model = ModelDallE2
+	}
+	m, ok := imageModels[model]
+	return model, m, ok
+}
+
+// validateImageCreate checks options which depend on the model.
+// Options of unknown models are not checked.
+func validateImageCreate(opts *ImageCreateOptions) error {
+	model, m, ok := imageModelFor(opts.Model)
+	if !ok {
+		return nil
+	}
+	if err := m.check(model, opts.Prompt, opts.N, opts.Size, opts.ResponseFormat, []imageOption{
+		{"quality", opts.Quality, m.qualities},
+		{"style", opts.Style, m.styles},
+		{"background", opts.Background, m.backgrounds},
+		{"output_format", opts.OutputFormat, m.outputFormats},
+	}); err != nil {
+		return err
+	}
+	if opts.OutputCompression != 0 {
+		if len(m.outputFormats) == 0 {
+			return fmt.Errorf("%w: %s doesn't support output_compression", ErrUnsupportedImageOption, model)
+		}
+		if opts.OutputFormat != OutputFormatJPEG && opts.OutputFormat != OutputFormatWEBP {
+			return fmt.Errorf("%w: output_compression requires jpeg or webp output_format", ErrUnsupportedImageOption)
+		}
+	}
+	if opts.Background == BackgroundTransparent && opts.OutputFormat == OutputFormatJPEG {
+		return fmt.Errorf("%w: transparent background requires png or webp output_format", ErrUnsupportedImageOption)
+	}
+	return nil
+}
+
+// validateImageEdit checks options which depend on the model.
+// Options of unknown models are not checked.
+func validateImageEdit(opts *ImageEditOptions) error {
+	model, m, ok := imageModelFor(opts.Model)
+	if !ok {
+		return nil
+	}
+	return m.check(model, opts.Prompt, opts.N, opts.Size, opts.ResponseFormat, []imageOption{
+		{"quality", opts.Quality, m.qualities},
+	})
+}
+
+// imageOption is the option with values allowed by the model.
+type imageOption struct {
+	name    string
+	value   string
+	allowed []string
+}
+
+// check checks the prompt, the number of images, the size, the response format and other options.
+func (m imageModel) check(model Model, prompt string, n int, size Size, responseFormat string, options []imageOption) error {
+	if l := len([]rune(prompt)); l > m.maxPrompt {
+		return fmt.Errorf("%w: %s prompt length is %d, maximum is %d", ErrUnsupportedImageOption, model, l, m.maxPrompt)
+	}
+	if n > m.maxN {
+		return fmt.Errorf("%w: %s can generate at most %d images, got %d", ErrUnsupportedImageOption, model, m.maxN, n)
+	}
+	if size != "" && !containsSize(m.sizes, size) {
+		return fmt.Errorf("%w: %s size must be one of %v, got %s", ErrUnsupportedImageOption, model, m.sizes, size)
+	}
+	for _, o := range options {
+		if o.value != "" && !contains(o.allowed, o.value) {
+			if len(o.allowed) == 0 {
+				return fmt.Errorf("%w: %s doesn't support %s", ErrUnsupportedImageOption, model, o.name)
+			}
+			return fmt.Errorf("%w: %s %s must be one of %v, got %s", ErrUnsupportedImageOption, model, o.name, o.allowed, o.value)
+		}
+	}
+	if responseFormat != "" && !m.responseFormat {
+		return fmt.Errorf("%w: %s doesn't support response_format", ErrUnsupportedImageOption, model)
+	}
+	return nil
+}
+
+func containsSize(sizes []Size, size Size) bool {
+	for _, s := range sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 type ImageCreateResponse struct {
-	Created int `json:"created"`
-	Data    []struct {
-		Url string `json:"url"`
-	} `json:"data"`
+	Created int     `json:"created"`
+	Data    []Image `json:"data"`
+}
+
+// MaxImageDownloadSize is the maximum size of the image downloaded by Image.Bytes.
+const MaxImageDownloadSize = 32 << 20
+
+// ErrNoImageData is returned when the image has neither URL nor base64 data.
+var ErrNoImageData = errors.New("image has no data")
+
+// Image is the generated image, returned as URL or base64 encoded data depending on the response format.
+// Use Bytes, Decode or Save to get the image regardless of the format.
+type Image struct {
+	// URL of the image, if the response format is url. The URL expires after an hour.
+	Url string `json:"url,omitempty"`
+	// Base64 encoded image, if the response format is b64_json.
+	B64JSON string `json:"b64_json,omitempty"`
+	// The prompt that was used to generate the image, if there was any revision to the prompt.
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+
+	engine *Engine
+}
+
+// Bytes returns the encoded image. The base64 data is decoded, the URL is downloaded
+// using the HTTP client of the engine which returned the image.
+// Downloaded images larger than MaxImageDownloadSize are rejected.
+func (img *Image) Bytes(ctx context.Context) ([]byte, error) {
+	switch {
+	case img.B64JSON != "":
+		b, err := base64.StdEncoding.DecodeString(img.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64 image: %w", err)
+		}
+		return b, nil
+	case img.Url != "":
+		return img.download(ctx)
+	}
+	return nil, ErrNoImageData
+}
+
+func (img *Image) download(ctx context.Context) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client := http.DefaultClient
+	if img.engine != nil {
+		client = img.engine.client
+	}
+	// The URL is signed, so the API key must not be sent
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, img.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download image: status code %d", resp.StatusCode)
+	}
+	if resp.ContentLength > MaxImageDownloadSize {
+		return nil, fmt.Errorf("download image: size %d exceeds %d bytes", resp.ContentLength, MaxImageDownloadSize)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download image: %w", err)
+	}
+	if len(b) > MaxImageDownloadSize {
+		return nil, fmt.Errorf("download image: size exceeds %d bytes", MaxImageDownloadSize)
+	}
+	return b, nil
+}
+
+// Decode returns the decoded image. PNG, JPEG and GIF images are supported.
+func (img *Image) Decode(ctx context.Context) (image.Image, error) {
+	b, err := img.Bytes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	return m, nil
+}
+
+// Save writes the image to the PNG file. Images in other formats are converted to PNG.
+func (img *Image) Save(ctx context.Context, path string) error {
+	b, err := img.Bytes(ctx)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(b, pngSignature) {
+		m, _, err := image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("decode image: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, m); err != nil {
+			return fmt.Errorf("encode png: %w", err)
+		}
+		b = buf.Bytes()
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Images returns all decoded images of the response.
+func (r *ImageCreateResponse) Images(ctx context.Context) ([]image.Image, error) {
+	images := make([]image.Image, len(r.Data))
+	for i := range r.Data {
+		m, err := r.Data[i].Decode(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		images[i] = m
+	}
+	return images, nil
+}
+
+// Save writes all images of the response to PNG files in the directory, which is created if needed.
+// Files are named by the creation time and the index of the image, e.g. 1589478378-0.png.
+// It returns paths of the written files.
+func (r *ImageCreateResponse) Save(ctx context.Context, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(r.Data))
+	for i := range r.Data {
+		path := filepath.Join(dir, fmt.Sprintf("%d-%d.png", r.Created, i))
+		if err := r.Data[i].Save(ctx, path); err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+func (r *ImageCreateResponse) setEngine(e *Engine) {
+	for i := range r.Data {
+		r.Data[i].engine = e
+	}
 }
 
 // ImageCreate given a prompt and/or an input image, the model will generate a new image.
@@ -59,18 +417,32 @@ func (e *Engine) ImageCreate(ctx context.Context, opts *ImageCreateOptions) (*Im
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	url := e.apiBaseURL + "/images/generations"
-	if len(opts.Size) == 0 {
-		opts.Size = SizeSmall
+	if err := validateImageCreate(opts); err != nil {
+		return nil, err
 	}
-	if len(opts.ResponseFormat) == 0 {
-		opts.ResponseFormat = ResponseFormatUrl
+	if opts.Model != "" {
+		if err := checkModel(opts.Model, EndpointImageGenerations); err != nil {
+			return nil, err
+		}
+	}
+	uri, err := e.deploymentURL("/images/generations", opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Model == "" || opts.Model == ModelDallE2 {
+		// Defaults of the client preceding model selection
+		if len(opts.Size) == 0 {
+			opts.Size = SizeSmall
+		}
+		if len(opts.ResponseFormat) == 0 {
+			opts.ResponseFormat = ResponseFormatUrl
+		}
 	}
 	r, err := marshalJson(opts)
 	if err != nil {
 		return nil, err
 	}
-	req, err := e.newReq(ctx, http.MethodPost, url, "json", r)
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", r)
 	if err != nil {
 		return nil, err
 	}
@@ -82,63 +454,101 @@ func (e *Engine) ImageCreate(ctx context.Context, opts *ImageCreateOptions) (*Im
 	if err := unmarshal(resp, &jsonResp); err != nil {
 		return nil, err
 	}
+	jsonResp.setEngine(e)
 	return &jsonResp, nil
 }
 
+// MaxImageFileSize is the maximum size of the image and the mask uploaded to edit and variation endpoints.
+const MaxImageFileSize = 4 << 20
+
+// Errors returned when the uploaded image doesn't meet requirements of the API.
+var (
+	ErrImageNotPNG       = errors.New("image is not a PNG file")
+	ErrImageNotSquare    = errors.New("image is not square")
+	ErrImageTooLarge     = errors.New("image is larger than 4MB")
+	ErrImageMaskMismatch = errors.New("mask dimensions don't match the image")
+)
+
+// pngSignature is the first 8 bytes of any PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
 type ImageEditOptions struct {
+	// The model to use for image editing. Must be dall-e-2 or gpt-image-1.
+	//
+	// Default: dall-e-2
+	Model Model
 	// The image to edit. Must be a valid PNG file, less than 4MB, and square.
 	// If mask is not provided, image must have transparency, which will be used as the mask.
-	Image string `binding:"required"`
+	Image io.Reader `binding:"required"`
+	// The name of the image file.
+	//
+	// Default: image.png
+	ImageName string
 	// An additional image whose fully transparent areas (e.g. where alpha is zero)
 	// indicate where image should be edited. Must be a valid PNG file, less than 4MB,
 	// and have the same dimensions as image.
-	Mask string `binding:"omitempty"`
-	// A text description of the desired image(s). The maximum length is 1000 characters.
-	Prompt string `binding:"required,max=1000"`
+	Mask io.Reader
+	// The name of the mask file.
+	//
+	// Default: mask.png
+	MaskName string
+	// A text description of the desired image(s). The maximum length is 1000 characters for dall-e-2
+	// and 32000 characters for gpt-image-1.
+	Prompt string `binding:"required"`
 	// The number of images to generate.
 	// Must be between 1 and 10.
-	N int `binding:"min=1,max=10"`
+	N int `binding:"omitempty,min=1,max=10"`
 	// The size of the generated images.
-	// Must be one of 256x256, 512x512, or 1024x1024.
-	Size string `binding:"omitempty,oneof=256x256 512x512 1024x1024"`
+	// Must be one of 256x256, 512x512, or 1024x1024 for dall-e-2 (default 256x256),
+	// and one of 1024x1024, 1536x1024, 1024x1536 or auto for gpt-image-1.
+	Size Size
+	// The quality of the image that will be generated.
+	// Must be one of low, medium, high or auto for gpt-image-1.
+	Quality string
 	// The format in which the generated images are returned.
-	// Must be one of url or b64_json
+	// Must be one of url or b64_json. Not supported by gpt-image-1, which always returns b64_json.
 	ResponseFormat string `binding:"omitempty,oneof=url b64_json"`
 }
 
-type ImageEditResponse struct {
-	Created int `json:"created"`
-	Data    []struct {
-		Url string `json:"url"`
-	} `json:"data"`
-}
+type ImageEditResponse = ImageCreateResponse
 
 // ImageEdit creates an edited or extended image given an original image and a prompt.
+// The image and the mask are checked before uploading, see MaxImageFileSize and ErrImage* errors.
 //
 // Docs: https://beta.openai.com/docs/api-reference/images/create-edit
 func (e *Engine) ImageEdit(ctx context.Context, opts *ImageEditOptions) (*ImageEditResponse, error) {
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	uri := e.apiBaseURL + "/images/edits"
+	if err := validateImageEdit(opts); err != nil {
+		return nil, err
+	}
+	if opts.Model != "" {
+		if err := checkModel(opts.Model, EndpointImageEdits); err != nil {
+			return nil, err
+		}
+	}
+	uri, err := e.deploymentURL("/images/edits", opts.Model)
+	if err != nil {
+		return nil, err
+	}
 	if opts.N == 0 {
 		opts.N = 1
 	}
-	if len(opts.Size) == 0 {
-		opts.Size = SizeSmall
-	}
-	if len(opts.ResponseFormat) == 0 {
-		opts.ResponseFormat = ResponseFormatUrl
+	if opts.Model == "" || opts.Model == ModelDallE2 {
+		// Defaults of the client preceding model selection
+		if len(opts.Size) == 0 {
+			opts.Size = SizeSmall
+		}
+		if len(opts.ResponseFormat) == 0 {
+			opts.ResponseFormat = ResponseFormatUrl
+		}
 	}
-	postValues := url.Values{
-		"image":           []string{opts.Image},
-		"mask":            []string{opts.Mask},
-		"prompt":          []string{opts.Prompt},
-		"n":               []string{strconv.Itoa(opts.N)},
-		"size":            []string{opts.Size},
-		"response_format": []string{opts.ResponseFormat},
+	body, contentType, err := newImageEditBody(opts)
+	if err != nil {
+		return nil, err
 	}
-	req, err := e.newReq(ctx, http.MethodPost, uri, "formData", strings.NewReader(postValues.Encode()))
+	req, err := e.newReq(ctx, http.MethodPost, uri, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -150,39 +560,88 @@ func (e *Engine) ImageEdit(ctx context.Context, opts *ImageEditOptions) (*ImageE
 	if err := unmarshal(resp, &jsonResp); err != nil {
 		return nil, err
 	}
+	jsonResp.setEngine(e)
 	return &jsonResp, nil
 }
 
+func newImageEditBody(opts *ImageEditOptions) (io.Reader, string, error) {
+	img, width, height, err := readPNG(opts.Image)
+	if err != nil {
+		return nil, "", fmt.Errorf("image: %w", err)
+	}
+	if width != height {
+		return nil, "", fmt.Errorf("image: %w: %dx%d", ErrImageNotSquare, width, height)
+	}
+	var mask []byte
+	if opts.Mask != nil {
+		var maskWidth, maskHeight int
+		mask, maskWidth, maskHeight, err = readPNG(opts.Mask)
+		if err != nil {
+			return nil, "", fmt.Errorf("mask: %w", err)
+		}
+		if maskWidth != width || maskHeight != height {
+			return nil, "", fmt.Errorf("mask: %w: %dx%d, image is %dx%d", ErrImageMaskMismatch, maskWidth, maskHeight, width, height)
+		}
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writeFormFile(writer, "image", fileName(opts.ImageName, "image.png"), img); err != nil {
+		return nil, "", err
+	}
+	if mask != nil {
+		if err := writeFormFile(writer, "mask", fileName(opts.MaskName, "mask.png"), mask); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writeFormFields(writer, []formField{
+		{"model", string(opts.Model)},
+		{"prompt", opts.Prompt},
+		{"n", strconv.Itoa(opts.N)},
+		{"size", string(opts.Size)},
+		{"quality", opts.Quality},
+		{"response_format", opts.ResponseFormat},
+	}); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("close writer: %w", err)
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
 type ImageVariationOptions struct {
-	// The image to edit. Must be a valid PNG file, less than 4MB, and square.
-	// If mask is not provided, image must have transparency, which will be used as the mask.
-	Image string `binding:"required"`
+	// The image to use as the basis for the variation(s). Must be a valid PNG file, less than 4MB, and square.
+	Image io.Reader `binding:"required"`
+	// The name of the image file.
+	//
+	// Default: image.png
+	ImageName string
 	// The number of images to generate.
 	// Must be between 1 and 10.
-	N int `binding:"min=1,max=10"`
+	N int `binding:"omitempty,min=1,max=10"`
 	// The size of the generated images.
 	// Must be one of 256x256, 512x512, or 1024x1024.
-	Size string `binding:"oneof=256x256 512x512 1024x1024"`
+	Size Size `binding:"omitempty,oneof=256x256 512x512 1024x1024"`
 	// The format in which the generated images are returned.
 	// Must be one of url or b64_json
 	ResponseFormat string `binding:"omitempty,oneof=url b64_json"`
 }
 
-type ImageVariationResponse struct {
-	Created int `json:"created"`
-	Data    []struct {
-		Url string `json:"url"`
-	} `json:"data"`
-}
+type ImageVariationResponse = ImageCreateResponse
 
 // ImageVariation creates a variation of a given image.
+// The image is checked before uploading, see MaxImageFileSize and ErrImage* errors.
 //
 // Docs: https://beta.openai.com/docs/api-reference/images/create-variation
 func (e *Engine) ImageVariation(ctx context.Context, opts *ImageVariationOptions) (*ImageCreateResponse, error) {
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	uri := e.apiBaseURL + "/images/variations"
+	uri, err := e.deploymentURL("/images/variations", "")
+	if err != nil {
+		return nil, err
+	}
 	if opts.N == 0 {
 		opts.N = 1
 	}
@@ -192,13 +651,11 @@ func (e *Engine) ImageVariation(ctx context.Context, opts *ImageVariationOptions
 	if len(opts.ResponseFormat) == 0 {
 		opts.ResponseFormat = ResponseFormatUrl
 	}
-	postValues := url.Values{
-		"model":           []string{opts.Image},
-		"n":               []string{strconv.Itoa(opts.N)},
-		"size":            []string{opts.Size},
-		"response_format": []string{opts.ResponseFormat},
+	body, contentType, err := newImageVariationBody(opts)
+	if err != nil {
+		return nil, err
 	}
-	req, err := e.newReq(ctx, http.MethodPost, uri, "formData", strings.NewReader(postValues.Encode()))
+	req, err := e.newReq(ctx, http.MethodPost, uri, contentType, body)
 	if err != nil {
 		return nil, err
 	}
@@ -210,5 +667,95 @@ func (e *Engine) ImageVariation(ctx context.Context, opts *ImageVariationOptions
 	if err := unmarshal(resp, &jsonResp); err != nil {
 		return nil, err
 	}
+	jsonResp.setEngine(e)
 	return &jsonResp, nil
 }
+
+func newImageVariationBody(opts *ImageVariationOptions) (io.Reader, string, error) {
+	img, width, height, err := readPNG(opts.Image)
+	if err != nil {
+		return nil, "", fmt.Errorf("image: %w", err)
+	}
+	if width != height {
+		return nil, "", fmt.Errorf("image: %w: %dx%d", ErrImageNotSquare, width, height)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writeFormFile(writer, "image", fileName(opts.ImageName, "image.png"), img); err != nil {
+		return nil, "", err
+	}
+	if err := writeFormFields(writer, []formField{
+		{"n", strconv.Itoa(opts.N)},
+		{"size", string(opts.Size)},
+		{"response_format", opts.ResponseFormat},
+	}); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("close writer: %w", err)
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// readPNG reads the PNG file up to MaxImageFileSize and returns its content and dimensions.
+func readPNG(r io.Reader) ([]byte, int, int, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageFileSize+1))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("read: %w", err)
+	}
+	if len(data) > MaxImageFileSize {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, 0, 0, ErrImageNotPNG
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: %v", ErrImageNotPNG, err)
+	}
+	return data, cfg.Width, cfg.Height, nil
+}
+
+// quoteEscaper escapes quoted parameters of the Content-Disposition header like multipart.Writer.CreateFormFile.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// writeFormFile writes the PNG image as the form file. Unlike multipart.Writer.CreateFormFile,
+// the part has image/png content type instead of application/octet-stream.
+func writeFormFile(writer *multipart.Writer, field, name string, data []byte) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(field), quoteEscaper.Replace(name)))
+	h.Set("Content-Type", "image/png")
+	file, err := writer.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("create form file %s: %w", field, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", field, err)
+	}
+	return nil
+}
+
+type formField struct {
+	name, value string
+}
+
+// writeFormFields writes fields with not empty values.
+func writeFormFields(writer *multipart.Writer, fields []formField) error {
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if err := writer.WriteField(f.name, f.value); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func fileName(name, defaultName string) string {
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
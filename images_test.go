@@ -4,58 +4,359 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
-	"log"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
-func TestImageCreate(t *testing.T) {
-	e := New(os.Getenv("OPENAI_KEY"))
-	r, err := e.ImageCreate(context.Background(), &ImageCreateOptions{
-		Prompt: "Future human",
-		Size:   SizeSmall,
+func TestImageEdit(t *testing.T) {
+	img, mask := testPNG(t, 64, 64), testPNG(t, 64, 64)
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, "/images/edits", r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "A cat wearing a hat", r.FormValue("prompt"))
+		assert.Equal(t, "2", r.FormValue("n"))
+		assert.Equal(t, string(Size512), r.FormValue("size"))
+		assert.Equal(t, ResponseFormatUrl, r.FormValue("response_format"))
+		f, h, err := r.FormFile("image")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "cat.png", h.Filename)
+			assert.Equal(t, "image/png", h.Header.Get("Content-Type"))
+			b, _ := io.ReadAll(f)
+			assert.Equal(t, img, b)
+		}
+		_, h, err = r.FormFile("mask")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "mask.png", h.Filename)
+			assert.Equal(t, "image/png", h.Header.Get("Content-Type"))
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"created":1,"data":[{"url":"https://example.com/1.png"},{"url":"https://example.com/2.png"}]}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	r, err := e.ImageEdit(context.Background(), &ImageEditOptions{
+		Image:     bytes.NewReader(img),
+		ImageName: "cat.png",
+		Mask:      bytes.NewReader(mask),
+		Prompt:    "A cat wearing a hat",
+		N:         2,
+		Size:      Size512,
 	})
-	if err != nil {
-		log.Fatal(err)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts, "buffered image must be uploaded again")
+	assert.Len(t, r.Data, 2)
+}
+
+func TestImageEditModels(t *testing.T) {
+	img := testPNG(t, 64, 64)
+	var form map[string][]string
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		form = r.MultipartForm.Value
+		w.Write([]byte(`{"created":1,"data":[{"b64_json":""}]}`))
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	_, err := e.ImageEdit(ctx, &ImageEditOptions{
+		Model:   ModelGPTImage1,
+		Image:   bytes.NewReader(img),
+		Prompt:  "cat",
+		Size:    Size1536x1024,
+		Quality: QualityHigh,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"model": {"gpt-image-1"}, "prompt": {"cat"}, "n": {"1"}, "size": {"1536x1024"}, "quality": {"high"},
+	}, form)
+
+	testCases := []struct {
+		name string
+		opts ImageEditOptions
+	}{
+		{"model without edits", ImageEditOptions{Model: ModelDallE3}},
+		{"dall-e-2 size", ImageEditOptions{Size: Size1536x1024}},
+		{"dall-e-2 quality", ImageEditOptions{Quality: QualityHigh}},
+		{"dall-e-2 prompt", ImageEditOptions{Prompt: strings.Repeat("a", 1001)}},
+		{"gpt-image-1 size", ImageEditOptions{Model: ModelGPTImage1, Size: Size256}},
+		{"gpt-image-1 response format", ImageEditOptions{Model: ModelGPTImage1, ResponseFormat: ResponseFormatUrl}},
 	}
-	if b, err := json.MarshalIndent(r, "", "  "); err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println(string(b))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.Image = bytes.NewReader(img)
+			if opts.Prompt == "" {
+				opts.Prompt = "cat"
+			}
+			_, err := e.ImageEdit(ctx, &opts)
+			assert.Error(t, err)
+		})
 	}
+	assert.Len(t, paths, 1, "invalid options must not be sent")
+
+	// The model selects the Azure deployment
+	paths = nil
+	e = NewWithOptions("test", WithAzure(AzureConfig{
+		Endpoint:    srv.URL,
+		Deployments: map[Model]string{ModelGPTImage1: "image-edits"},
+	}))
+	_, err = e.ImageEdit(ctx, &ImageEditOptions{Model: ModelGPTImage1, Image: bytes.NewReader(img), Prompt: "cat"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/openai/deployments/image-edits/images/edits"}, paths)
 }
 
-func TestImageEdit(t *testing.T) {
-	e := New(os.Getenv("OPENAI_KEY"))
-	r, err := e.ImageEdit(context.Background(), &ImageEditOptions{
-		Image:  "https://oaidalleapiprodscus.blob.core.windows.net/private/org-eSqeiZkMEDQsrwhfqU2bgbwd/user-KNsBZQnL5BwvITLR3rihZvNx/img-R0loYA2FUFBUGvkewj2E52fJ.png?st=2022-12-30T21%3A07%3A15Z\u0026se=2022-12-30T23%3A07%3A15Z\u0026sp=r\u0026sv=2021-08-06\u0026sr=b\u0026rscd=inline\u0026rsct=image/png\u0026skoid=6aaadede-4fb3-4698-a8f6-684d7786b067\u0026sktid=a48cca56-e6da-484e-a814-9c849652bcb3\u0026skt=2022-12-30T20%3A27%3A06Z\u0026ske=2022-12-31T20%3A27%3A06Z\u0026sks=b\u0026skv=2021-08-06\u0026sig=Freo5NF9iVtQvs0KXhJRhIjzRmV7CAmfDvaVEHJqo3w%3D",
-		Prompt: "Write a little bit of Wikipedia. What is that?",
-		Size:   SizeSmall,
+func TestImageVariation(t *testing.T) {
+	img := testPNG(t, 32, 32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/images/variations", r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Empty(t, r.FormValue("model"))
+		assert.Equal(t, "1", r.FormValue("n"))
+		assert.Equal(t, string(SizeSmall), r.FormValue("size"))
+		_, h, err := r.FormFile("image")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "image.png", h.Filename)
+			assert.Equal(t, "image/png", h.Header.Get("Content-Type"))
+		}
+		w.Write([]byte(`{"created":1,"data":[{"url":"https://example.com/1.png"}]}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	r, err := e.ImageVariation(context.Background(), &ImageVariationOptions{Image: bytes.NewReader(img)})
+	assert.NoError(t, err)
+	assert.Len(t, r.Data, 1)
+}
+
+func TestImageUploadChecks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid image must not be uploaded")
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	large := append(testPNG(t, 8, 8), make([]byte, MaxImageFileSize)...)
+	testCases := []struct {
+		name    string
+		image   []byte
+		mask    []byte
+		wantErr error
+	}{
+		{"not png", []byte("\xff\xd8\xff\xe0 jpeg"), nil, ErrImageNotPNG},
+		{"corrupted png", pngSignature, nil, ErrImageNotPNG},
+		{"too large", large, nil, ErrImageTooLarge},
+		{"not square", testPNG(t, 64, 32), nil, ErrImageNotSquare},
+		{"mask mismatch", testPNG(t, 64, 64), testPNG(t, 32, 32), ErrImageMaskMismatch},
+		{"mask not png", testPNG(t, 64, 64), []byte("GIF89a"), ErrImageNotPNG},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &ImageEditOptions{Image: bytes.NewReader(tc.image), Prompt: "cat"}
+			if tc.mask != nil {
+				opts.Mask = bytes.NewReader(tc.mask)
+			}
+			_, err := e.ImageEdit(ctx, opts)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.mask == nil {
+				_, err = e.ImageVariation(ctx, &ImageVariationOptions{Image: bytes.NewReader(tc.image)})
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestImageResponse(t *testing.T) {
+	pngImage := testPNG(t, 4, 4)
+	var jpegImage bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&jpegImage, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil))
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/images/generations", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"created": 1589478378,
+			"data": []map[string]string{
+				{"b64_json": base64.StdEncoding.EncodeToString(pngImage), "revised_prompt": "A fluffy cat"},
+				{"url": srv.URL + "/image.jpg"},
+			},
+		})
 	})
-	if err != nil {
-		log.Fatal(err)
+	mux.HandleFunc("/image.jpg", func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "API key must not be sent to image storage")
+		w.Write(jpegImage.Bytes())
+	})
+	mux.HandleFunc("/large.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(MaxImageDownloadSize+1))
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+	r, err := e.ImageCreate(ctx, &ImageCreateOptions{Prompt: "cat", Size: SizeSmall})
+	assert.NoError(t, err)
+	assert.Equal(t, "A fluffy cat", r.Data[0].RevisedPrompt)
+
+	b, err := r.Data[0].Bytes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, pngImage, b)
+	b, err = r.Data[1].Bytes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, jpegImage.Bytes(), b)
+
+	images, err := r.Images(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, images[0].Bounds().Dx())
+	assert.Equal(t, 8, images[1].Bounds().Dx())
+
+	dir := filepath.Join(t.TempDir(), "images")
+	paths, err := r.Save(ctx, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "1589478378-0.png"), filepath.Join(dir, "1589478378-1.png")}, paths)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		assert.NoError(t, err)
+		_, err = png.DecodeConfig(f)
+		assert.NoError(t, err, "JPEG image must be converted to PNG")
+		f.Close()
+	}
+
+	img := Image{Url: srv.URL + "/large.png", engine: e}
+	_, err = img.Bytes(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exceeds")
 	}
-	if b, err := json.MarshalIndent(r, "", "  "); err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println(string(b))
+	img.Url = srv.URL + "/missing.png"
+	_, err = img.Bytes(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "status code 403")
 	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	img.Url = srv.URL + "/image.jpg"
+	_, err = img.Bytes(canceled)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = (&Image{}).Bytes(ctx)
+	assert.ErrorIs(t, err, ErrNoImageData)
 }
 
-func TestImageVariation(t *testing.T) {
-	e := New(os.Getenv("OPENAI_KEY"))
-	r, err := e.ImageVariation(context.Background(), &ImageVariationOptions{
-		Image: "https://oaidalleapiprodscus.blob.core.windows.net/private/org-eSqeiZkMEDQsrwhfqU2bgbwd/user-KNsBZQnL5BwvITLR3rihZvNx/img-R0loYA2FUFBUGvkewj2E52fJ.png?st=2022-12-30T21%3A07%3A15Z\u0026se=2022-12-30T23%3A07%3A15Z\u0026sp=r\u0026sv=2021-08-06\u0026sr=b\u0026rscd=inline\u0026rsct=image/png\u0026skoid=6aaadede-4fb3-4698-a8f6-684d7786b067\u0026sktid=a48cca56-e6da-484e-a814-9c849652bcb3\u0026skt=2022-12-30T20%3A27%3A06Z\u0026ske=2022-12-31T20%3A27%3A06Z\u0026sks=b\u0026skv=2021-08-06\u0026sig=Freo5NF9iVtQvs0KXhJRhIjzRmV7CAmfDvaVEHJqo3w%3D",
-		Size:  SizeSmall,
+// testPNG returns the transparent PNG image of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestImageCreateModels(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"created":1,"data":[{"b64_json":""}]}`))
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	// dall-e-2 keeps defaults of the client
+	_, err := e.ImageCreate(ctx, &ImageCreateOptions{Prompt: "cat"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"prompt": "cat", "size": string(Size256), "response_format": ResponseFormatUrl}, body)
+
+	_, err = e.ImageCreate(ctx, &ImageCreateOptions{
+		Model:   ModelDallE3,
+		Prompt:  "cat",
+		Size:    Size1792x1024,
+		Quality: QualityHD,
+		Style:   StyleNatural,
+		User:    "user-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"model": "dall-e-3", "prompt": "cat", "size": string(Size1792x1024), "quality": QualityHD, "style": StyleNatural, "user": "user-1",
+	}, body)
+
+	_, err = e.ImageCreate(ctx, &ImageCreateOptions{
+		Model:             ModelGPTImage1,
+		Prompt:            "cat",
+		Size:              SizeAuto,
+		Quality:           QualityHigh,
+		Background:        BackgroundTransparent,
+		OutputFormat:      OutputFormatWEBP,
+		OutputCompression: 80,
 	})
-	if err != nil {
-		log.Fatal(err)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"model": "gpt-image-1", "prompt": "cat", "size": string(SizeAuto), "quality": QualityHigh,
+		"background": BackgroundTransparent, "output_format": OutputFormatWEBP, "output_compression": float64(80),
+	}, body)
+
+	// Options of unknown models are not checked
+	_, err = e.ImageCreate(ctx, &ImageCreateOptions{Model: "my-image-model", Prompt: "cat", Size: "640x480", N: 3})
+	assert.NoError(t, err)
+}
+
+func TestImageCreateValidation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid options must not be sent")
+	}))
+	defer srv.Close()
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+
+	long := string(bytes.Repeat([]byte("a"), 1001))
+	testCases := []struct {
+		name string
+		opts ImageCreateOptions
+	}{
+		{"dall-e-2 prompt too long", ImageCreateOptions{Prompt: long}},
+		{"dall-e-2 large size", ImageCreateOptions{Prompt: "cat", Size: Size1792x1024}},
+		{"dall-e-2 style", ImageCreateOptions{Model: ModelDallE2, Prompt: "cat", Style: StyleVivid}},
+		{"dall-e-2 background", ImageCreateOptions{Prompt: "cat", Background: BackgroundOpaque}},
+		{"dall-e-3 n", ImageCreateOptions{Model: ModelDallE3, Prompt: "cat", N: 2}},
+		{"dall-e-3 small size", ImageCreateOptions{Model: ModelDallE3, Prompt: "cat", Size: Size256}},
+		{"dall-e-3 quality", ImageCreateOptions{Model: ModelDallE3, Prompt: "cat", Quality: QualityHigh}},
+		{"dall-e-3 output format", ImageCreateOptions{Model: ModelDallE3, Prompt: "cat", OutputFormat: OutputFormatPNG}},
+		{"gpt-image-1 response format", ImageCreateOptions{Model: ModelGPTImage1, Prompt: "cat", ResponseFormat: ResponseFormatUrl}},
+		{"gpt-image-1 style", ImageCreateOptions{Model: ModelGPTImage1, Prompt: "cat", Style: StyleVivid}},
+		{"gpt-image-1 size", ImageCreateOptions{Model: ModelGPTImage1, Prompt: "cat", Size: Size1792x1024}},
+		{"gpt-image-1 png compression", ImageCreateOptions{Model: ModelGPTImage1, Prompt: "cat", OutputFormat: OutputFormatPNG, OutputCompression: 50}},
+		{"gpt-image-1 transparent jpeg", ImageCreateOptions{Model: ModelGPTImage1, Prompt: "cat", Background: BackgroundTransparent, OutputFormat: OutputFormatJPEG}},
 	}
-	if b, err := json.MarshalIndent(r, "", "  "); err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println(string(b))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := e.ImageCreate(context.Background(), &tc.opts)
+			assert.ErrorIs(t, err, ErrUnsupportedImageOption)
+		})
 	}
+
+	_, err := e.ImageCreate(context.Background(), &ImageCreateOptions{Model: ModelGPT4, Prompt: "cat"})
+	assert.ErrorIs(t, err, ErrIncompatibleModel)
 }
@@ -0,0 +1,271 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLogBodySize is the maximum size of request and response bodies logged at debug level.
+const maxLogBodySize = 2048
+
+// redactedValue replaces secrets and values of redacted fields in logs.
+const redactedValue = "[REDACTED]"
+
+// WithLogger sets the logger used to log requests made by the engine.
+// Each request is logged with its method, path, model, status, latency, request ID and the number
+// of attempts. At debug level retries and truncated request and response bodies are logged too.
+//
+// API key and organization ID are never logged, use WithRedactedFields to hide other sensitive data.
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Engine) {
+		e.logger = logger
+	}
+}
+
+// WithRedactedFields sets names of JSON fields which values are redacted in logged bodies,
+// e.g. "prompt", "messages" or "input". Fields are redacted at any depth of the body.
+func WithRedactedFields(fields ...string) Option {
+	return func(e *Engine) {
+		if e.redactedFields == nil {
+			e.redactedFields = make(map[string]bool)
+		}
+		for _, f := range fields {
+			e.redactedFields[f] = true
+		}
+	}
+}
+
+func (e *Engine) logRequest(req *http.Request, info requestInfo, resp *http.Response, err error, attempts int, latency time.Duration) {
+	ctx := req.Context()
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !e.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", info.endpoint),
+	}
+	model := info.model
+	if model == "" {
+		model = requestModel(req)
+	}
+	if model != "" {
+		attrs = append(attrs, slog.String("model", string(model)))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if id := requestID(resp.Header); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+	}
+	attrs = append(attrs, slog.Duration("latency", latency), slog.Int("attempts", attempts))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", e.redactSecrets(err.Error())))
+	}
+	if e.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.String("request_body", e.requestBodyForLog(req)))
+		if resp != nil && err == nil {
+			attrs = append(attrs, slog.String("response_body", e.responseBodyForLog(resp)))
+		}
+	}
+	e.logger.LogAttrs(ctx, level, "openai request", attrs...)
+}
+
+func (e *Engine) logRetry(req *http.Request, info requestInfo, resp *http.Response, err error, attempt int, delay time.Duration) {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", info.endpoint),
+		slog.Int("attempt", attempt),
+		slog.Duration("delay", delay),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", e.redactSecrets(err.Error())))
+	}
+	e.logger.LogAttrs(req.Context(), slog.LevelDebug, "openai retry", attrs...)
+}
+
+// requestBodyForLog returns the redacted and truncated JSON body of the request.
+// Other bodies, e.g. multipart uploads, are not read as they may be streamed.
+func (e *Engine) requestBodyForLog(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	contentType := req.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		mediaType, _, _ := strings.Cut(contentType, ";")
+		return "[" + mediaType + " body]"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	b, err := readLogBody(body)
+	if err != nil {
+		return ""
+	}
+	size := req.ContentLength
+	if size == 0 {
+		size = -1
+	}
+	return e.redactBody(b, size)
+}
+
+// responseBodyForLog returns the redacted and truncated JSON or text body of the response.
+// Only the logged part of the body is read and it is put back, so the whole body can be read again.
+// Streams and other bodies are not read.
+func (e *Engine) responseBodyForLog(resp *http.Response) string {
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return "[stream]"
+	}
+	if !strings.HasPrefix(contentType, "application/json") && !strings.HasPrefix(contentType, "text/") {
+		mediaType, _, _ := strings.Cut(contentType, ";")
+		if resp.ContentLength >= 0 {
+			return "[" + strconv.FormatInt(resp.ContentLength, 10) + " bytes of " + mediaType + "]"
+		}
+		return "[" + mediaType + " body]"
+	}
+	b, err := readLogBody(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+	if err != nil {
+		return ""
+	}
+	return e.redactBody(b, resp.ContentLength)
+}
+
+// readLogBody reads at most maxLogBodySize bytes of the body plus one byte
+// to know whether the body is truncated.
+func readLogBody(r io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r, maxLogBodySize+1))
+}
+
+// redactBody returns the redacted body read by readLogBody truncated to maxLogBodySize bytes.
+// Size is the size of the whole body or -1 if it's unknown.
+func (e *Engine) redactBody(b []byte, size int64) string {
+	truncated := len(b) > maxLogBodySize
+	s := string(b)
+	if len(e.redactedFields) != 0 {
+		if trimmed := bytes.TrimSpace(b); len(trimmed) != 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			// Truncated JSON is redacted up to the last complete token, so values of
+			// redacted fields are never logged even if they are cut off.
+			s = redactJSON(b, e.redactedFields)
+		}
+	}
+	s = e.redactSecrets(s)
+	if len(s) > maxLogBodySize {
+		s = truncateString(s, maxLogBodySize)
+		truncated = true
+	}
+	if truncated {
+		if size >= 0 {
+			return s + "... (truncated, " + strconv.FormatInt(size, 10) + " bytes total)"
+		}
+		return s + "... (truncated)"
+	}
+	return s
+}
+
+// truncateString truncates s to at most n bytes without splitting UTF-8 encoded runes.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// redactSecrets replaces the API key and organization ID in s.
+func (e *Engine) redactSecrets(s string) string {
+	e.mu.RLock()
+	apiKey, organizationId := e.apiKey, e.organizationId
+	e.mu.RUnlock()
+	for _, secret := range []string{apiKey, organizationId, e.projectId} {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedValue)
+		}
+	}
+	return s
+}
+
+// redactJSON re-encodes JSON replacing values of the fields with redactedValue.
+// Invalid or truncated JSON is re-encoded up to the last complete token.
+func redactJSON(b []byte, fields map[string]bool) string {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	type container struct {
+		object bool
+		// Number of keys and values written to the container.
+		n int
+	}
+	var (
+		sb    strings.Builder
+		stack []container
+	)
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return sb.String()
+		}
+		if d, ok := t.(json.Delim); ok && (d == '}' || d == ']') {
+			sb.WriteRune(rune(d))
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		var key bool
+		if len(stack) != 0 {
+			c := &stack[len(stack)-1]
+			key = c.object && c.n%2 == 0
+			switch {
+			case c.object && !key:
+				sb.WriteByte(':')
+			case c.n != 0:
+				sb.WriteByte(',')
+			}
+			c.n++
+		}
+		switch t := t.(type) {
+		case json.Delim:
+			sb.WriteRune(rune(t))
+			stack = append(stack, container{object: t == '{'})
+		case string:
+			v, _ := json.Marshal(t)
+			sb.Write(v)
+			if key && fields[t] {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return sb.String()
+				}
+				sb.WriteString(`:"` + redactedValue + `"`)
+				stack[len(stack)-1].n++
+			}
+		case json.Number:
+			sb.WriteString(t.String())
+		case bool:
+			sb.WriteString(strconv.FormatBool(t))
+		case nil:
+			sb.WriteString("null")
+		}
+	}
+}
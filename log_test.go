@@ -0,0 +1,136 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-Request-Id", "req_123")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/models" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Incorrect API key provided: sk-secret","type":"invalid_request_error","code":"invalid_api_key"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Secret answer"}}]}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	e := NewWithOptions("sk-secret",
+		WithBaseURL(srv.URL),
+		WithOrganization("org-secret"),
+		WithRetryPolicy(testRetryPolicy),
+		WithLogger(logger),
+		WithRedactedFields("content"),
+	)
+	resp, err := e.ChatCompletion(context.Background(), &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Secret prompt"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Secret answer", resp.Choices[0].Message.Content, "logged body must be restored")
+	_, err = e.ListModels(context.Background())
+	assert.Error(t, err)
+
+	out := buf.String()
+	assert.NotContains(t, out, "sk-secret")
+	assert.NotContains(t, out, "org-secret")
+	assert.NotContains(t, out, "Secret prompt")
+	assert.NotContains(t, out, "Secret answer")
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, "openai retry", records[0]["msg"])
+	assert.Equal(t, float64(503), records[0]["status"])
+
+	assert.Equal(t, "openai request", records[1]["msg"])
+	assert.Equal(t, "INFO", records[1]["level"])
+	assert.Equal(t, "/chat/completions", records[1]["path"])
+	assert.Equal(t, "gpt-3.5-turbo", records[1]["model"])
+	assert.Equal(t, "req_123", records[1]["request_id"])
+	assert.Equal(t, float64(2), records[1]["attempts"])
+	assert.Contains(t, records[1]["request_body"], `"content":"[REDACTED]"`)
+	assert.Contains(t, records[1]["response_body"], `"content":"[REDACTED]"`)
+
+	assert.Equal(t, "WARN", records[2]["level"])
+	assert.Equal(t, float64(401), records[2]["status"])
+	assert.Contains(t, records[2]["error"], "Incorrect API key provided: [REDACTED]")
+}
+
+func TestLoggerBodies(t *testing.T) {
+	e := NewWithOptions("test")
+	redacting := NewWithOptions("test", WithRedactedFields("b"))
+	assert.Equal(t, `{"a":[{"b":"[REDACTED]"}],"c":"d","e":[1.50,true,null,{}]}`, redacting.redactBody([]byte(` {"a": [{"b": {"x": 1}}], "c": "d", "e": [1.50, true, null, {}]}`), -1))
+	assert.Equal(t, "plain text", redacting.redactBody([]byte("plain text"), -1))
+
+	// Truncated JSON never contains cut off values of redacted fields
+	truncated := append([]byte(`{"a":"x","b":"`), bytes.Repeat([]byte("secret "), maxLogBodySize)...)
+	assert.Equal(t, `{"a":"x","b"... (truncated)`, redacting.redactBody(truncated[:maxLogBodySize+1], -1))
+
+	long := e.redactBody(bytes.Repeat([]byte("a"), maxLogBodySize+1), maxLogBodySize+10)
+	assert.Equal(t, strings.Repeat("a", maxLogBodySize)+"... (truncated, 2058 bytes total)", long)
+	// Runes are not split
+	runes := e.redactBody(append([]byte("a"), bytes.Repeat([]byte("я"), maxLogBodySize)...)[:maxLogBodySize+1], -1)
+	assert.Equal(t, "a"+strings.Repeat("я", maxLogBodySize/2-1)+"... (truncated)", runes)
+
+	req, err := e.newReq(context.Background(), http.MethodPost, "http://localhost/audio/transcriptions", "multipart/form-data; boundary=x", strings.NewReader("--x--"))
+	assert.NoError(t, err)
+	assert.Equal(t, "[multipart/form-data body]", e.requestBodyForLog(req))
+}
+
+func TestLoggerLargeResponse(t *testing.T) {
+	body := `{"data":"` + strings.Repeat("x", 10*maxLogBodySize) + `"}`
+	e := NewWithOptions("test")
+
+	resp := &http.Response{
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	s := e.responseBodyForLog(resp)
+	assert.True(t, strings.HasSuffix(s, "... (truncated, 20491 bytes total)"))
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b), "whole body must be restored")
+
+	// Other bodies are not read
+	r := strings.NewReader(body)
+	resp = &http.Response{
+		Header:        http.Header{"Content-Type": {"application/octet-stream"}},
+		Body:          io.NopCloser(r),
+		ContentLength: -1,
+	}
+	assert.Equal(t, "[application/octet-stream body]", e.responseBodyForLog(resp))
+	assert.Equal(t, len(body), r.Len())
+	resp.ContentLength = int64(len(body))
+	assert.Equal(t, "[20491 bytes of application/octet-stream]", e.responseBodyForLog(resp))
+}
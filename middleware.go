@@ -0,0 +1,58 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Handler sends the request to the API and returns the response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the handler to inspect or modify requests and responses.
+// It can short-circuit the request by returning a response without calling next,
+// e.g. to serve it from cache.
+type Middleware func(next Handler) Handler
+
+// errNilResponse is returned when the middleware returns neither response nor error.
+var errNilResponse = errors.New("openai: middleware returned nil response and nil error")
+
+// Use adds middlewares which are called for each attempt of every request made by the engine,
+// after the request is fully built, including headers and multipart body.
+//
+// Middlewares are called in the order they are added: the first one sees the request first
+// and the response last.
+func (e *Engine) Use(middlewares ...Middleware) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.middlewares = append(e.middlewares, middlewares...)
+}
+
+// WithMiddleware adds middlewares to the engine, see Engine.Use.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(e *Engine) {
+		e.middlewares = append(e.middlewares, middlewares...)
+	}
+}
+
+// handler returns the HTTP client wrapped by middlewares.
+func (e *Engine) handler() Handler {
+	e.mu.RLock()
+	middlewares := e.middlewares
+	e.mu.RUnlock()
+	h := Handler(e.client.Do)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := h(req)
+		// The client closes the body, but the middleware may return without calling it
+		closeBody(req)
+		if resp == nil && err == nil {
+			return nil, errNilResponse
+		}
+		return resp, err
+	}
+}
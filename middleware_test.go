@@ -0,0 +1,99 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, "rotated", r.Header.Get("X-Key"))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"text":"Hallo"}`))
+	}))
+	defer srv.Close()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				resp, err := next(req)
+				calls = append(calls, name+" response")
+				return resp, err
+			}
+		}
+	}
+	var contentTypes []string
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy), WithMiddleware(trace("first")))
+	e.Use(trace("second"), func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			contentTypes = append(contentTypes, req.Header.Get("Content-Type"))
+			req.Header.Set("X-Key", "rotated")
+			return next(req)
+		}
+	})
+	resp, err := e.Transcribe(context.Background(), &TranscribeOptions{
+		AudioOptions: &AudioOptions{
+			File:        bytes.NewReader([]byte("RIFF")),
+			AudioFormat: "wav",
+			Model:       ModelWhisper,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hallo", resp.Text)
+	assert.Equal(t, []string{
+		"first request", "second request", "second response", "first response",
+		"first request", "second request", "second response", "first response",
+	}, calls, "middlewares must be called in order for each attempt")
+	assert.Len(t, contentTypes, 2)
+	assert.True(t, strings.HasPrefix(contentTypes[0], "multipart/form-data"))
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request must be served by middleware")
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	e.Use(func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"choices":[{"message":{"role":"assistant","content":"Cached"}}]}`)),
+				Request:    req,
+			}, nil
+		}
+	})
+	resp, err := e.ChatCompletion(context.Background(), &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Cached", resp.Choices[0].Message.Content)
+
+	e = NewWithOptions("test", WithBaseURL(srv.URL), WithMiddleware(func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, nil
+		}
+	}))
+	_, err = e.ListModels(context.Background())
+	assert.ErrorIs(t, err, errNilResponse)
+}
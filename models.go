@@ -26,8 +26,8 @@ const (
 
 // GPT-3 models can understand and generate natural language.
 // These models were superceded by the more powerful GPT-3.5 generation models.
-// However, the original GPT-3 base models (davinci, curie, ada, and babbage) are current the only
-// models that are available to fine-tune.
+// Their base models (davinci, curie, ada, and babbage) were fine-tuned with the legacy fine-tunes API,
+// which is replaced by babbage-002 and davinci-002.
 const (
 	ModelGPT3Ada            Model = "ada"
 	ModelGPT3Babbage        Model = "babbage"
@@ -43,6 +43,15 @@ const (
 	DefaultModel = ModelGPT3TextDavinci003
 )
 
+// GPT base models can understand and generate natural language, but are not trained with instruction following.
+// They replace the original GPT-3 base models and can be fine-tuned.
+//
+// Learn more: https://platform.openai.com/docs/models/gpt-base
+const (
+	ModelBabbage002 Model = "babbage-002"
+	ModelDavinci002 Model = "davinci-002"
+)
+
 // GPT-3.5 models can understand and generate natural language or code.
 // Our most capable and cost effective model in the GPT-3.5 family is gpt-3.5-turbo which has been
 // optimized for chat but works well for traditional completions tasks as well.
@@ -67,6 +76,12 @@ const (
 	ModelGPT40314    Model = "gpt-4-0314"
 )
 
+// Edit models are used with Edit to rewrite the input according to the instruction.
+const (
+	ModelTextDavinciEdit001 Model = "text-davinci-edit-001"
+	ModelCodeDavinciEdit001 Model = "code-davinci-edit-001"
+)
+
 // ModelWhisper is a general-purpose speech recognition model.
 // It is trained on a large dataset of diverse audio and is also a multi-task model that can perform multilingual
 // speech recognition as well as speech translation and language identification. The Whisper v2-large model is
@@ -77,6 +92,26 @@ const (
 	ModelWhisper Model = "whisper-1"
 )
 
+// Image models generate images from a text prompt.
+// DALL·E 2 can also edit images and create their variations, GPT Image can edit images.
+//
+// Learn more: https://platform.openai.com/docs/models/dall-e
+const (
+	ModelDallE2    Model = "dall-e-2"
+	ModelDallE3    Model = "dall-e-3"
+	ModelGPTImage1 Model = "gpt-image-1"
+)
+
+// Embedding models convert text into numerical vectors which can be used
+// for search, clustering, recommendations and classification.
+//
+// Learn more: https://platform.openai.com/docs/models/embeddings
+const (
+	ModelTextEmbeddingAda002 Model = "text-embedding-ada-002"
+	ModelTextEmbedding3Small Model = "text-embedding-3-small"
+	ModelTextEmbedding3Large Model = "text-embedding-3-large"
+)
+
 type ListModelsResponse struct {
 	Data []struct {
 		ID      Model  `json:"id"`
@@ -90,8 +125,8 @@ type ListModelsResponse struct {
 //
 // Docs: https://beta.openai.com/docs/api-reference/models/list
 func (e *Engine) ListModels(ctx context.Context) (*ListModelsResponse, error) {
-	url := e.apiBaseURL + "/models"
-	req, err := e.newReq(ctx, http.MethodGet, url, "", nil)
+	uri := e.apiURL("/models")
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -125,8 +160,8 @@ func (e *Engine) RetrieveModel(ctx context.Context, opts *RetrieveModelOptions)
 	if err := e.validate.StructCtx(ctx, opts); err != nil {
 		return nil, err
 	}
-	url := e.apiBaseURL + "/models/" + string(opts.ID)
-	req, err := e.newReq(ctx, http.MethodGet, url, "", nil)
+	uri := e.apiURL("/models/" + string(opts.ID))
+	req, err := e.newReq(ctx, http.MethodGet, uri, "", nil)
 	if err != nil {
 		return nil, err
 	}
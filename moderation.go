@@ -58,7 +58,7 @@ func (e *Engine) Moderate(ctx context.Context, input string) (*ModerationRespons
 		return nil, err
 	}
 
-	uri := e.apiBaseURL + "/moderations"
+	uri := e.apiURL("/moderations")
 	req, err := e.newReq(ctx, http.MethodPost, uri, "json", &buf)
 	if err != nil {
 		return nil, err
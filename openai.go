@@ -9,45 +9,81 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// Engine is the OpenAI API client. It is safe for concurrent use by multiple goroutines.
 type Engine struct {
+	mu             sync.RWMutex // guards apiKey, organizationId and middlewares
 	apiKey         string
 	apiBaseURL     string
 	organizationId string
+	projectId      string
+	userAgent      string
+	headers        http.Header
+	timeout        time.Duration
+	retryPolicy    RetryPolicy
+	rateLimiter    *RateLimiter
+	middlewares    []Middleware
+	logger         *slog.Logger
+	redactedFields map[string]bool
+	azure          *AzureConfig
 	client         *http.Client
 	validate       *validator.Validate
-	n              int
+	stats          statsCollector
 }
 
 const (
+	defaultBaseURL   = "https://api.openai.com/v1"
 	defaultMaxTokens = 1024
 )
 
 // New is used to initialize engine.
 func New(apiKey string) *Engine {
+	return NewWithOptions(apiKey)
+}
+
+// NewWithOptions is used to initialize engine configured by options.
+func NewWithOptions(apiKey string, opts ...Option) *Engine {
 	e := &Engine{
 		apiKey:     apiKey,
-		apiBaseURL: "https://api.openai.com/v1",
+		apiBaseURL: defaultBaseURL,
+		headers:    make(http.Header),
 		client:     &http.Client{},
-		validate:   validator.New(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.timeout != 0 {
+		// Copy client to not modify the one passed by user
+		client := *e.client
+		client.Timeout = e.timeout
+		e.client = &client
 	}
 	v := validator.New()
 	v.SetTagName("binding")
+	v.RegisterCustomTypeFunc(embeddingInputValue, EmbeddingInput{})
+	v.RegisterCustomTypeFunc(promptValue, Prompt{})
 	e.validate = v
 	return e
 }
 
 // SetApiKey is used to set API key to access OpenAI API.
 func (e *Engine) SetApiKey(apiKey string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.apiKey = apiKey
 }
 
 // SetOrganizationId is used to set organization ID if user belongs to multiple organizations.
 func (e *Engine) SetOrganizationId(organizationId string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.organizationId = organizationId
 }
 
@@ -62,9 +98,29 @@ func (e *Engine) newReq(ctx context.Context, method string, uri string, postType
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.apiKey))
-	if len(e.organizationId) != 0 {
-		req.Header.Set("OpenAI-Organization", e.organizationId)
+	for k, v := range e.headers {
+		// Credentials are set by the engine only
+		if k == "Authorization" || (k == "Api-Key" && e.azure != nil) {
+			continue
+		}
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if len(e.userAgent) != 0 {
+		req.Header.Set("User-Agent", e.userAgent)
+	}
+	e.mu.RLock()
+	apiKey, organizationId := e.apiKey, e.organizationId
+	e.mu.RUnlock()
+	if e.azure != nil {
+		req.Header.Set("Api-Key", apiKey)
+	} else {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+	if len(organizationId) != 0 {
+		req.Header.Set("OpenAI-Organization", organizationId)
+	}
+	if len(e.projectId) != 0 {
+		req.Header.Set("OpenAI-Project", e.projectId)
 	}
 	// Setup Content-Type depends on postType
 	switch {
@@ -79,26 +135,22 @@ func (e *Engine) newReq(ctx context.Context, method string, uri string, postType
 }
 
 func (e *Engine) doReq(req *http.Request) (*http.Response, error) {
-	e.n++ // increment number of requests
-	resp, err := e.client.Do(req)
-	if err != nil {
-		return nil, err
+	info := e.newRequestInfo(req, e.rateLimiter != nil)
+	start := time.Now()
+	resp, attempts, err := e.send(req, info)
+	latency := time.Since(start)
+	e.stats.recordRequest(info.endpoint, resp, err, attempts, latency)
+	// If we have not-success HTTP status code, read APIError
+	if err == nil && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		err = newAPIError(resp)
 	}
-	// Check for valid status code
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return resp, nil
+	if e.logger != nil {
+		e.logRequest(req, info, resp, err, attempts, latency)
 	}
-
-	// If we have not-success HTTP status code, unmarshal to APIError
-	var apiErr APIError
-	if err := unmarshal(resp, &apiErr); err != nil {
-		return nil, err
-	}
-	if apiErr.Err.StatusCode == 0 {
-		// Overwrite apiErr status code if it's zero
-		apiErr.Err.StatusCode = resp.StatusCode
+	if err != nil {
+		return resp, withAttempts(attempts, err)
 	}
-	return resp, apiErr
+	return resp, nil
 }
 
 func unmarshal(resp *http.Response, v interface{}) error {
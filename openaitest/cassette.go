@@ -0,0 +1,465 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openaitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// RecordEnv is the environment variable which switches cassettes created by Cassette to record mode.
+const RecordEnv = "OPENAITEST_RECORD"
+
+// redacted replaces values of redacted headers in cassettes.
+const redacted = "REDACTED"
+
+// RecorderMode represents whether the recorder records or replays interactions.
+type RecorderMode int
+
+const (
+	// ModeReplay replays recorded interactions without sending requests.
+	ModeReplay RecorderMode = iota
+	// ModeRecord sends requests and records interactions.
+	ModeRecord
+)
+
+// Interaction is a recorded request and response pair.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   Body        `json:"body"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       Body        `json:"body"`
+}
+
+// Body is encoded in cassettes as string if it's valid UTF-8, or as {"base64": "..."} otherwise.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var v struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(v.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+type cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Matcher reports whether the actual request matches the recorded one.
+type Matcher func(actual *RecordedRequest, recorded *RecordedRequest) bool
+
+// MatchMethod matches requests by HTTP method.
+func MatchMethod(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	return actual.Method == recorded.Method
+}
+
+// MatchPath matches requests by URL path, ignoring the host.
+func MatchPath(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	return urlPart(actual.URL, false) == urlPart(recorded.URL, false)
+}
+
+// MatchQuery matches requests by URL query.
+func MatchQuery(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	return urlPart(actual.URL, true) == urlPart(recorded.URL, true)
+}
+
+// MatchBody matches requests by body. JSON bodies are compared after normalization
+// and multipart bodies are compared by fields, so key order and boundaries don't matter.
+func MatchBody(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	return normalizeBody(actual) == normalizeBody(recorded)
+}
+
+// MatchJSONBody matches requests by JSON body, ignoring formatting and key order.
+// Bodies that are not JSON are compared byte by byte.
+func MatchJSONBody(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	var a, r interface{}
+	if json.Unmarshal(actual.Body, &a) != nil || json.Unmarshal(recorded.Body, &r) != nil {
+		return bytes.Equal(actual.Body, recorded.Body)
+	}
+	return reflect.DeepEqual(a, r)
+}
+
+// MatchMultipartFields matches multipart requests by their fields and files, ignoring the boundary.
+// Requests that are not multipart are compared byte by byte.
+func MatchMultipartFields(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	a, aErr := multipartFields(actual)
+	r, rErr := multipartFields(recorded)
+	if aErr != nil || rErr != nil {
+		return bytes.Equal(actual.Body, recorded.Body)
+	}
+	return reflect.DeepEqual(a, r)
+}
+
+// DefaultMatchers are used by the recorder if no matchers are set.
+var DefaultMatchers = []Matcher{MatchMethod, MatchPath, MatchQuery, MatchBody}
+
+// DefaultRedactedHeaders are the headers which values are not saved to cassettes.
+var DefaultRedactedHeaders = []string{"Authorization", "OpenAI-Organization", "OpenAI-Project", "Api-Key"}
+
+// Recorder is the http.RoundTripper which records interactions to the cassette file,
+// or replays them from it.
+type Recorder struct {
+	path      string
+	mode      RecorderMode
+	transport http.RoundTripper
+	matchers  []Matcher
+	redact    []string
+
+	mu       sync.Mutex
+	cassette cassette
+	used     []bool
+}
+
+type RecorderOption func(*Recorder)
+
+// WithTransport sets the transport used to send requests in record mode.
+// By default http.DefaultTransport is used.
+func WithTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// WithMatchers sets matchers used to find the recorded interaction for the request.
+// The request matches the interaction if all matchers return true.
+func WithMatchers(matchers ...Matcher) RecorderOption {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRedactedHeaders adds headers which values are not saved to cassettes.
+func WithRedactedHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redact = append(r.redact, names...)
+	}
+}
+
+// NewRecorder creates the recorder for the cassette file at path.
+// In replay mode the cassette is loaded from the file, in record mode
+// interactions are saved to the file by Save.
+func NewRecorder(path string, mode RecorderMode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matchers:  DefaultMatchers,
+		redact:    append([]string(nil), DefaultRedactedHeaders...),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("openaitest: read cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("openaitest: decode cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Cassette returns the engine option which records interactions of the test to
+// testdata/<name>.json, or replays them if RecordEnv is not set. Recorded interactions
+// are saved when the test finishes.
+func Cassette(t testing.TB, name string, opts ...RecorderOption) openai.Option {
+	t.Helper()
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+	r, err := NewRecorder(filepath.Join("testdata", name+".json"), mode, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return openai.WithHTTPClient(r.Client())
+}
+
+// Client returns the HTTP client using the recorder as transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Save writes recorded interactions to the cassette file. It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("openaitest: encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("openaitest: save cassette: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("openaitest: save cassette: %w", err)
+	}
+	return nil
+}
+
+// RoundTrip records or replays the interaction.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	actual, err := r.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeRecord {
+		return r.record(req, actual)
+	}
+	return r.replay(req, actual)
+}
+
+func (r *Recorder) record(req *http.Request, actual *RecordedRequest) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: *actual,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       body,
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction matching the request.
+func (r *Recorder) replay(req *http.Request, actual *RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.cassette.Interactions {
+		interaction := &r.cassette.Interactions[i]
+		if r.used[i] || !r.match(actual, &interaction.Request) {
+			continue
+		}
+		r.used[i] = true
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, r.missingError(actual)
+}
+
+func (r *Recorder) match(actual *RecordedRequest, recorded *RecordedRequest) bool {
+	for _, m := range r.matchers {
+		if !m(actual, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// missingError returns the error with diff between the request and the closest unused interaction.
+func (r *Recorder) missingError(actual *RecordedRequest) error {
+	msg := fmt.Sprintf("openaitest: no recorded interaction in %s matches request %s", r.path, requestLine(actual))
+	var closest *RecordedRequest
+	for i := range r.cassette.Interactions {
+		recorded := &r.cassette.Interactions[i].Request
+		if r.used[i] {
+			continue
+		}
+		if MatchMethod(actual, recorded) && MatchPath(actual, recorded) {
+			closest = recorded
+			break
+		}
+		if closest == nil {
+			closest = recorded
+		}
+	}
+	if closest == nil {
+		return errors.New(msg + ": all recorded interactions are used")
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(describeRequest(closest)),
+		B:        difflib.SplitLines(describeRequest(actual)),
+		FromFile: "recorded",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	return errors.New(msg + ", diff with the closest one:\n" + diff)
+}
+
+func (r *Recorder) recordRequest(req *http.Request) (*RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return &RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: r.redactHeader(req.Header),
+		Body:   body,
+	}, nil
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range r.redact {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// urlPart returns the path of the URL, or its normalized query if query is true.
+func urlPart(rawURL string, query bool) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if query {
+		return u.Query().Encode()
+	}
+	return u.Path
+}
+
+// normalizeBody returns the body in the form independent of JSON formatting and multipart boundary.
+func normalizeBody(req *RecordedRequest) string {
+	if fields, err := multipartFields(req); err == nil {
+		lines := make([]string, 0, len(fields))
+		for name, values := range fields {
+			for _, v := range values {
+				lines = append(lines, name+": "+v)
+			}
+		}
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	}
+	var v interface{}
+	if err := json.Unmarshal(req.Body, &v); err == nil {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b)
+	}
+	return string(req.Body)
+}
+
+// multipartFields returns values of multipart fields. File fields are represented by the file name and content.
+func multipartFields(req *RecordedRequest) (map[string][]string, error) {
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if mediaType != "multipart/form-data" {
+		return nil, fmt.Errorf("not multipart: %s", mediaType)
+	}
+	reader := multipart.NewReader(bytes.NewReader(req.Body), params["boundary"])
+	fields := make(map[string][]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		value := string(b)
+		if part.FileName() != "" {
+			value = fmt.Sprintf("file %q (%d bytes, sha256 %x)", part.FileName(), len(b), sha256.Sum256(b))
+		}
+		fields[part.FormName()] = append(fields[part.FormName()], value)
+	}
+}
+
+func requestLine(req *RecordedRequest) string {
+	s := req.Method + " " + urlPart(req.URL, false)
+	if q := urlPart(req.URL, true); q != "" {
+		s += "?" + q
+	}
+	return s
+}
+
+func describeRequest(req *RecordedRequest) string {
+	return requestLine(req) + "\n\n" + normalizeBody(req) + "\n"
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openaitest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func transcribeOptions(language string) *openai.TranscribeOptions {
+	return &openai.TranscribeOptions{
+		AudioOptions: &openai.AudioOptions{
+			File:        bytes.NewReader([]byte{0x52, 0x49, 0x46, 0x46, 0xff, 0xfe}),
+			AudioFormat: "wav",
+			Model:       openai.ModelWhisper,
+		},
+		Language: language,
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+	ctx := context.Background()
+
+	srv := NewServer()
+	rec, err := NewRecorder(path, ModeRecord)
+	assert.NoError(t, err)
+	e := srv.Engine(openai.WithHTTPClient(rec.Client()), openai.WithOrganization("org-secret"))
+	_, err = e.ChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	_, err = e.Transcribe(ctx, transcribeOptions("de"))
+	assert.NoError(t, err)
+	assert.NoError(t, rec.Save())
+	srv.Close()
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), APIKey)
+	assert.NotContains(t, string(b), "org-secret")
+	assert.Contains(t, string(b), redacted)
+
+	// Replay doesn't need the server, multipart boundary and JSON formatting don't matter
+	rec, err = NewRecorder(path, ModeReplay)
+	assert.NoError(t, err)
+	e = openai.NewWithOptions("other-key", openai.WithBaseURL(srv.URL), openai.WithHTTPClient(rec.Client()))
+	resp, err := e.Transcribe(ctx, transcribeOptions("de"))
+	assert.NoError(t, err)
+	assert.Equal(t, Text, resp.Text)
+	chat, err := e.ChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, Text, chat.Choices[0].Message.Content)
+
+	// Each interaction is replayed once
+	_, err = e.ChatCompletion(ctx, chatOptions)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "all recorded interactions are used")
+
+	rec, err = NewRecorder(path, ModeReplay)
+	assert.NoError(t, err)
+	e = openai.NewWithOptions("other-key", openai.WithBaseURL(srv.URL), openai.WithHTTPClient(rec.Client()))
+	_, err = e.Transcribe(ctx, transcribeOptions("fr"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no recorded interaction")
+		assert.Contains(t, err.Error(), "-language: de")
+		assert.Contains(t, err.Error(), "+language: fr")
+	}
+
+	// Matchers are configurable
+	rec, err = NewRecorder(path, ModeReplay, WithMatchers(MatchMethod, MatchPath))
+	assert.NoError(t, err)
+	e = openai.NewWithOptions("other-key", openai.WithBaseURL(srv.URL), openai.WithHTTPClient(rec.Client()))
+	_, err = e.Transcribe(ctx, transcribeOptions("fr"))
+	assert.NoError(t, err)
+}
+
+func TestCassette(t *testing.T) {
+	e := openai.NewWithOptions(os.Getenv("OPENAI_KEY"), Cassette(t, "chat_completion"))
+	resp, err := e.ChatCompletion(context.Background(), &openai.ChatCompletionOptions{
+		Model:    openai.ModelGPT3Dot5Turbo,
+		Messages: []openai.ChatMessage{{Role: openai.RoleUser, Content: "Say this is a test."}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(resp.Choices[0].Message.Content, "This is a test"))
+}
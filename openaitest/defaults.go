@@ -0,0 +1,423 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openaitest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Text is the text generated by default responses of the server.
+const Text = "This is a test."
+
+// Created is the Unix timestamp of objects in default responses of the server.
+const Created = 1677652288
+
+// PNG is the 1x1 transparent image returned by default image responses in b64_json format.
+var PNG = func() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	return buf.Bytes()
+}()
+
+// defaultDimensions is the number of dimensions of default embeddings.
+const defaultDimensions = 8
+
+type object = map[string]interface{}
+
+var defaultRoutes = []struct {
+	method string
+	path   string
+	handle func(w http.ResponseWriter, req *Request)
+}{
+	{http.MethodPost, "/completions", serveCompletion},
+	{http.MethodPost, "/chat/completions", serveChatCompletion},
+	{http.MethodPost, "/edits", serveEdit},
+	{http.MethodPost, "/embeddings", serveEmbeddings},
+	{http.MethodPost, "/moderations", serveModeration},
+	{http.MethodPost, "/images/generations", serveImages},
+	{http.MethodPost, "/images/edits", serveImages},
+	{http.MethodPost, "/images/variations", serveImages},
+	{http.MethodPost, "/audio/transcriptions", serveAudio},
+	{http.MethodPost, "/audio/translations", serveAudio},
+	{http.MethodGet, "/models", serveListModels},
+	{http.MethodGet, "/models/*", serveModel},
+	{http.MethodPost, "/files", serveUploadFile},
+	{http.MethodGet, "/files", serveListFiles},
+	{http.MethodGet, "/files/*", serveFile},
+	{http.MethodGet, "/files/*/content", serveFileContent},
+	{http.MethodDelete, "/files/*", serveDeleteFile},
+	{http.MethodPost, "/fine_tuning/jobs", serveCreateFineTuningJob},
+	{http.MethodGet, "/fine_tuning/jobs", serveListFineTuningJobs},
+	{http.MethodGet, "/fine_tuning/jobs/*", serveFineTuningJob},
+	{http.MethodPost, "/fine_tuning/jobs/*/cancel", serveFineTuningJob},
+	{http.MethodGet, "/fine_tuning/jobs/*/events", serveFineTuningEvents},
+	{http.MethodGet, "/fine_tuning/jobs/*/checkpoints", serveFineTuningCheckpoints},
+}
+
+var defaultHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Body: body}
+	for _, dr := range defaultRoutes {
+		rt := route{method: dr.method, path: splitPath(dr.path)}
+		if rt.match(r.Method, r.URL.Path) {
+			w.Header().Set("X-Request-Id", "req_"+strconv.FormatInt(time.Now().UnixNano(), 36))
+			dr.handle(w, req)
+			return
+		}
+	}
+	Error(http.StatusNotFound, "invalid_request_error", "unknown_url",
+		fmt.Sprintf("Unknown request URL: %s %s.", r.Method, r.URL.Path)).ServeHTTP(w, r)
+})
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	JSON(http.StatusOK, v).ServeHTTP(w, nil)
+}
+
+// pathSegment returns i-th segment of the request path.
+func pathSegment(req *Request, i int) string {
+	return splitPath(req.Path)[i]
+}
+
+func model(req *Request, fallback string) string {
+	if m, ok := req.JSON()["model"].(string); ok && m != "" {
+		return m
+	}
+	if m := req.FormValue("model"); m != "" {
+		return m
+	}
+	return fallback
+}
+
+func number(req *Request, name string, fallback int) int {
+	if n, ok := req.JSON()[name].(float64); ok && n > 0 {
+		return int(n)
+	}
+	if n, err := strconv.Atoi(req.FormValue(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// format returns response_format of JSON or form request.
+func format(req *Request) string {
+	if f, ok := req.JSON()["response_format"].(string); ok {
+		return f
+	}
+	return req.FormValue("response_format")
+}
+
+func isStream(req *Request) bool {
+	stream, _ := req.JSON()["stream"].(bool)
+	return stream
+}
+
+var defaultUsage = object{"prompt_tokens": 5, "completion_tokens": 5, "total_tokens": 10}
+
+func serveCompletion(w http.ResponseWriter, req *Request) {
+	m := model(req, "text-davinci-003")
+	n := number(req, "n", 1)
+	if isStream(req) {
+		var chunks []interface{}
+		for _, word := range strings.SplitAfter(Text, " ") {
+			for i := 0; i < n; i++ {
+				chunks = append(chunks, object{
+					"id": "cmpl-test", "object": "text_completion", "created": Created, "model": m,
+					"choices": []object{{"text": word, "index": i, "finish_reason": nil}},
+				})
+			}
+		}
+		Stream(chunks...).ServeHTTP(w, nil)
+		return
+	}
+	choices := make([]object, n)
+	for i := range choices {
+		choices[i] = object{"text": Text, "index": i, "finish_reason": "stop"}
+	}
+	writeJSON(w, object{
+		"id": "cmpl-test", "object": "text_completion", "created": Created, "model": m,
+		"choices": choices, "usage": defaultUsage,
+	})
+}
+
+func serveChatCompletion(w http.ResponseWriter, req *Request) {
+	m := model(req, "gpt-3.5-turbo")
+	n := number(req, "n", 1)
+	if isStream(req) {
+		var chunks []interface{}
+		chunk := func(i int, delta object, finishReason interface{}) {
+			chunks = append(chunks, object{
+				"id": "chatcmpl-test", "object": "chat.completion.chunk", "created": Created, "model": m,
+				"choices": []object{{"index": i, "delta": delta, "finish_reason": finishReason}},
+			})
+		}
+		for i := 0; i < n; i++ {
+			chunk(i, object{"role": "assistant", "content": ""}, nil)
+		}
+		for _, word := range strings.SplitAfter(Text, " ") {
+			for i := 0; i < n; i++ {
+				chunk(i, object{"content": word}, nil)
+			}
+		}
+		for i := 0; i < n; i++ {
+			chunk(i, object{}, "stop")
+		}
+		Stream(chunks...).ServeHTTP(w, nil)
+		return
+	}
+	choices := make([]object, n)
+	for i := range choices {
+		choices[i] = object{
+			"index":         i,
+			"message":       object{"role": "assistant", "content": Text},
+			"finish_reason": "stop",
+		}
+	}
+	writeJSON(w, object{
+		"id": "chatcmpl-test", "object": "chat.completion", "created": Created, "model": m,
+		"choices": choices, "usage": defaultUsage,
+	})
+}
+
+func serveEdit(w http.ResponseWriter, req *Request) {
+	n := number(req, "n", 1)
+	choices := make([]object, n)
+	for i := range choices {
+		choices[i] = object{"text": Text, "index": i}
+	}
+	writeJSON(w, object{"object": "edit", "created": Created, "choices": choices, "usage": defaultUsage})
+}
+
+func serveEmbeddings(w http.ResponseWriter, req *Request) {
+	body := req.JSON()
+	n := 1
+	if input, ok := body["input"].([]interface{}); ok {
+		n = len(input)
+	}
+	dims := number(req, "dimensions", defaultDimensions)
+	base64Format := body["encoding_format"] == "base64"
+	data := make([]object, n)
+	for i := range data {
+		// Deterministic unit vector which differs between inputs
+		vec := make([]float32, dims)
+		vec[i%dims] = 1
+		var emb interface{} = vec
+		if base64Format {
+			var buf bytes.Buffer
+			for _, v := range vec {
+				binary.Write(&buf, binary.LittleEndian, math.Float32bits(v))
+			}
+			emb = base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+		data[i] = object{"object": "embedding", "index": i, "embedding": emb}
+	}
+	writeJSON(w, object{
+		"object": "list", "data": data, "model": model(req, "text-embedding-ada-002"),
+		"usage": object{"prompt_tokens": n, "total_tokens": n},
+	})
+}
+
+func serveModeration(w http.ResponseWriter, req *Request) {
+	categories := object{}
+	scores := object{}
+	for _, c := range []string{"hate", "hate/threatening", "self-harm", "sexual", "sexual/minors", "violence", "violence/graphic"} {
+		categories[c] = false
+		scores[c] = 0.0001
+	}
+	writeJSON(w, object{
+		"id": "modr-test", "model": model(req, "text-moderation-latest"),
+		"results": []object{{"categories": categories, "category_scores": scores, "flagged": false}},
+	})
+}
+
+func serveImages(w http.ResponseWriter, req *Request) {
+	n := number(req, "n", 1)
+	data := make([]object, n)
+	for i := range data {
+		if format(req) == "b64_json" {
+			data[i] = object{"b64_json": base64.StdEncoding.EncodeToString(PNG)}
+		} else {
+			data[i] = object{"url": fmt.Sprintf("https://images.example.com/%d.png", i)}
+		}
+	}
+	writeJSON(w, object{"created": Created, "data": data})
+}
+
+func serveAudio(w http.ResponseWriter, req *Request) {
+	task := "transcribe"
+	if strings.HasSuffix(req.Path, "/translations") {
+		task = "translate"
+	}
+	switch format(req) {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, Text)
+	case "srt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "1\n00:00:00,000 --> 00:00:01,000\n%s\n\n", Text)
+	case "vtt":
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		fmt.Fprintf(w, "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n%s\n\n", Text)
+	case "verbose_json":
+		writeJSON(w, object{
+			"task": task, "language": "english", "duration": 1.0, "text": Text,
+			"segments": []object{{
+				"id": 0, "seek": 0, "start": 0.0, "end": 1.0, "text": Text, "tokens": []int{1, 2},
+				"temperature": 0.0, "avg_logprob": -0.2, "compression_ratio": 0.9, "no_speech_prob": 0.01,
+			}},
+			"words": []object{{"word": Text, "start": 0.0, "end": 1.0}},
+		})
+	default:
+		writeJSON(w, object{"text": Text})
+	}
+}
+
+func modelObject(id string) object {
+	return object{"id": id, "object": "model", "created": Created, "owned_by": "openai"}
+}
+
+func serveListModels(w http.ResponseWriter, req *Request) {
+	writeJSON(w, object{"object": "list", "data": []object{
+		modelObject("gpt-4"),
+		modelObject("gpt-3.5-turbo"),
+		modelObject("text-embedding-ada-002"),
+		modelObject("whisper-1"),
+	}})
+}
+
+func serveModel(w http.ResponseWriter, req *Request) {
+	writeJSON(w, modelObject(pathSegment(req, 1)))
+}
+
+func fileObject(id string, filename string, purpose string) object {
+	return object{
+		"id": id, "object": "file", "bytes": len(Text), "created_at": Created,
+		"filename": filename, "purpose": purpose,
+	}
+}
+
+func serveUploadFile(w http.ResponseWriter, req *Request) {
+	filename, content, err := req.FormFile("file")
+	if err != nil {
+		Error(http.StatusBadRequest, "invalid_request_error", "", "Missing file: "+err.Error()).ServeHTTP(w, nil)
+		return
+	}
+	f := fileObject("file-test", filename, req.FormValue("purpose"))
+	f["bytes"] = len(content)
+	writeJSON(w, f)
+}
+
+func serveListFiles(w http.ResponseWriter, req *Request) {
+	purpose := req.Query.Get("purpose")
+	if purpose == "" {
+		purpose = "fine-tune"
+	}
+	writeJSON(w, object{
+		"object": "list", "data": []object{fileObject("file-test", "train.jsonl", purpose)},
+		"first_id": "file-test", "last_id": "file-test", "has_more": false,
+	})
+}
+
+func serveFile(w http.ResponseWriter, req *Request) {
+	writeJSON(w, fileObject(pathSegment(req, 1), "train.jsonl", "fine-tune"))
+}
+
+func serveFileContent(w http.ResponseWriter, req *Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.WriteString(w, Text)
+}
+
+func serveDeleteFile(w http.ResponseWriter, req *Request) {
+	writeJSON(w, object{"id": pathSegment(req, 1), "object": "file", "deleted": true})
+}
+
+func fineTuningJob(id string, m string, status string) object {
+	job := object{
+		"id":               id,
+		"object":           "fine_tuning.job",
+		"created_at":       Created,
+		"model":            m,
+		"organization_id":  "org-test",
+		"status":           status,
+		"training_file":    "file-test",
+		"hyperparameters":  object{"n_epochs": "auto", "batch_size": "auto", "learning_rate_multiplier": "auto"},
+		"result_files":     []string{},
+		"finished_at":      nil,
+		"fine_tuned_model": nil,
+	}
+	if status == "succeeded" {
+		job["finished_at"] = Created + 3600
+		job["fine_tuned_model"] = "ft:" + m + ":org-test::test"
+		job["hyperparameters"] = object{"n_epochs": 3, "batch_size": 1, "learning_rate_multiplier": 2}
+		job["result_files"] = []string{"file-result"}
+		job["trained_tokens"] = 1000
+	}
+	return job
+}
+
+func serveCreateFineTuningJob(w http.ResponseWriter, req *Request) {
+	job := fineTuningJob("ftjob-test", model(req, "gpt-3.5-turbo"), "queued")
+	if f, ok := req.JSON()["training_file"].(string); ok {
+		job["training_file"] = f
+	}
+	writeJSON(w, job)
+}
+
+func serveListFineTuningJobs(w http.ResponseWriter, req *Request) {
+	writeJSON(w, object{
+		"object": "list", "has_more": false,
+		"data": []object{fineTuningJob("ftjob-test", "gpt-3.5-turbo", "succeeded")},
+	})
+}
+
+func serveFineTuningJob(w http.ResponseWriter, req *Request) {
+	status := "succeeded"
+	if strings.HasSuffix(req.Path, "/cancel") {
+		status = "cancelled"
+	}
+	writeJSON(w, fineTuningJob(pathSegment(req, 2), "gpt-3.5-turbo", status))
+}
+
+func serveFineTuningEvents(w http.ResponseWriter, req *Request) {
+	event := func(id string, message string, created int) object {
+		return object{
+			"id": id, "object": "fine_tuning.job.event", "created_at": created,
+			"level": "info", "message": message, "type": "message",
+		}
+	}
+	writeJSON(w, object{"object": "list", "has_more": false, "data": []object{
+		event("ftevent-2", "The job has successfully completed", Created+3600),
+		event("ftevent-1", "Fine-tuning job started", Created),
+	}})
+}
+
+func serveFineTuningCheckpoints(w http.ResponseWriter, req *Request) {
+	jobId := pathSegment(req, 2)
+	writeJSON(w, object{
+		"object": "list", "first_id": "ftckpt-test", "last_id": "ftckpt-test", "has_more": false,
+		"data": []object{{
+			"id":                          "ftckpt-test",
+			"object":                      "fine_tuning.job.checkpoint",
+			"created_at":                  Created + 3600,
+			"fine_tuned_model_checkpoint": "ft:gpt-3.5-turbo:org-test::test:ckpt-step-100",
+			"step_number":                 100,
+			"fine_tuning_job_id":          jobId,
+			"metrics":                     object{"step": 100, "train_loss": 0.5, "train_mean_token_accuracy": 0.9},
+		}},
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openaitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JSON responds with the status code and v encoded as JSON.
+// Strings and byte slices are written as is.
+func JSON(status int, v interface{}) http.Handler {
+	body, err := encode(v)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// Error responds with the status code and the error in the OpenAI error envelope.
+func Error(status int, typ string, code string, message string) http.Handler {
+	return JSON(status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    typ,
+			"code":    nullable(code),
+			"param":   nil,
+		},
+	})
+}
+
+// RateLimited responds with 429 Too Many Requests and Retry-After header set to retryAfter.
+func RateLimited(retryAfter time.Duration) http.Handler {
+	h := Error(http.StatusTooManyRequests, "requests", "rate_limit_exceeded", "Rate limit reached for requests")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After-Ms", strconv.FormatInt(retryAfter.Milliseconds(), 10))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ServerError responds with 500 Internal Server Error.
+func ServerError() http.Handler {
+	return Error(http.StatusInternalServerError, "server_error", "", "The server had an error while processing your request.")
+}
+
+// Malformed responds with the status code and a truncated JSON body.
+func Malformed(status int) http.Handler {
+	return JSON(status, `{"id":"cmpl-malformed","object":`)
+}
+
+// Slow sends the status and headers of h immediately, but delays the body by delay.
+// It's useful to test timeouts and context cancellation while reading the response.
+func Slow(delay time.Duration, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		flush(w)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+		}
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+// Stream responds with Server-Sent Events, one data event per chunk, followed by [DONE].
+// Chunks are encoded as in JSON.
+func Stream(chunks ...interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for _, chunk := range chunks {
+			b, err := encode(chunk)
+			if err != nil {
+				panic(fmt.Sprintf("openaitest: encode stream chunk: %v", err))
+			}
+			fmt.Fprintf(w, "data: %s\n\n", b)
+			flush(w)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flush(w)
+	})
+}
+
+// Sequence serves n-th request with n-th handler, the last handler serves all remaining requests.
+// For example, Sequence(ServerError(), Default()) fails the first request only.
+func Sequence(handlers ...http.Handler) http.Handler {
+	if len(handlers) == 0 {
+		panic("openaitest: Sequence requires at least one handler")
+	}
+	var mu sync.Mutex
+	var n int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		h := handlers[n]
+		if n < len(handlers)-1 {
+			n++
+		}
+		mu.Unlock()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Default responds as the server does when no handler is scripted.
+func Default() http.Handler {
+	return defaultHandler
+}
+
+func encode(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package openaitest provides a local fake OpenAI API server for hermetic tests.
+//
+// The server answers every endpoint supported by openai.Engine with canned responses.
+// Responses can be scripted per endpoint with Handle and the responders of this package,
+// and all received requests are captured for assertions:
+//
+//	e, srv := openaitest.NewEngine(t)
+//	srv.Handle(http.MethodPost, "/chat/completions", openaitest.Sequence(
+//		openaitest.RateLimited(0),
+//		openaitest.Default(),
+//	))
+//	resp, err := e.ChatCompletion(ctx, opts)
+//	req := srv.AssertCalled(t, http.MethodPost, "/chat/completions")
+package openaitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	openai "github.com/0x9ef/openai-go"
+)
+
+// APIKey is the API key used by engines returned by Server.Engine.
+const APIKey = "sk-test"
+
+// Server is a fake OpenAI API server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	routes   []route
+	requests []*Request
+}
+
+type route struct {
+	method  string
+	path    []string
+	handler http.Handler
+}
+
+// NewServer starts and returns a new server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// NewEngine starts a new server, which is closed when the test finishes,
+// and returns the engine pointed to it.
+func NewEngine(t testing.TB, opts ...openai.Option) (*openai.Engine, *Server) {
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s.Engine(opts...), s
+}
+
+// Engine returns a new engine pointed to the server. Options are applied after the base URL
+// is set, so they can wrap the HTTP client or set the retry policy.
+func (s *Server) Engine(opts ...openai.Option) *openai.Engine {
+	return openai.NewWithOptions(APIKey, append([]openai.Option{openai.WithBaseURL(s.URL)}, opts...)...)
+}
+
+// Handle scripts the response for requests with the given method and path.
+// Path segments equal to "*" match any segment, e.g. "/files/*".
+// Handlers registered later take precedence, unmatched requests are served by Default.
+func (s *Server) Handle(method string, path string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{method: method, path: splitPath(path), handler: h})
+}
+
+// HandleFunc is the same as Handle, but takes the handler function.
+func (s *Server) HandleFunc(method string, path string, f func(http.ResponseWriter, *http.Request)) {
+	s.Handle(method, path, http.HandlerFunc(f))
+}
+
+// Reset removes scripted handlers and captured requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = nil
+	s.requests = nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var h http.Handler = defaultHandler
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		if s.routes[i].match(r.Method, r.URL.Path) {
+			h = s.routes[i].handler
+			break
+		}
+	}
+	s.mu.Unlock()
+	h.ServeHTTP(w, r)
+}
+
+func (rt route) match(method string, path string) bool {
+	if rt.method != method {
+		return false
+	}
+	segments := splitPath(path)
+	if len(segments) != len(rt.path) {
+		return false
+	}
+	for i := range segments {
+		if rt.path[i] != "*" && rt.path[i] != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// Requests returns all captured requests in the order they were received.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// LastRequest returns the last captured request or nil if there were no requests.
+func (s *Server) LastRequest() *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// Calls returns captured requests with the given method and path, which may contain "*" segments.
+func (s *Server) Calls(method string, path string) []*Request {
+	rt := route{method: method, path: splitPath(path)}
+	var calls []*Request
+	for _, req := range s.Requests() {
+		if rt.match(req.Method, req.Path) {
+			calls = append(calls, req)
+		}
+	}
+	return calls
+}
+
+// AssertCalled fails the test if the server didn't receive a request with the given method and path.
+// It returns the last matching request, or nil if there is none.
+func (s *Server) AssertCalled(t testing.TB, method string, path string) *Request {
+	t.Helper()
+	calls := s.Calls(method, path)
+	if len(calls) == 0 {
+		t.Errorf("openaitest: expected %s %s to be called, received requests:\n%s", method, path, s.summary())
+		return nil
+	}
+	return calls[len(calls)-1]
+}
+
+// AssertNotCalled fails the test if the server received a request with the given method and path.
+func (s *Server) AssertNotCalled(t testing.TB, method string, path string) {
+	t.Helper()
+	if n := len(s.Calls(method, path)); n != 0 {
+		t.Errorf("openaitest: expected %s %s not to be called, but it was called %d times", method, path, n)
+	}
+}
+
+// AssertCallCount fails the test if the server didn't receive exactly n requests with the given method and path.
+func (s *Server) AssertCallCount(t testing.TB, method string, path string, n int) {
+	t.Helper()
+	if got := len(s.Calls(method, path)); got != n {
+		t.Errorf("openaitest: expected %s %s to be called %d times, but it was called %d times", method, path, n, got)
+	}
+}
+
+func (s *Server) summary() string {
+	requests := s.Requests()
+	if len(requests) == 0 {
+		return "\t(none)"
+	}
+	lines := make([]string, len(requests))
+	for i, req := range requests {
+		lines[i] = "\t" + req.Method + " " + req.Path
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Request is a request captured by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// DecodeJSON decodes the JSON body of the request into v.
+func (r *Request) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// JSON returns the JSON body of the request decoded into map, or nil if the body is not a JSON object.
+func (r *Request) JSON() map[string]interface{} {
+	var m map[string]interface{}
+	if err := r.DecodeJSON(&m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// FormValue returns the first value of the form field from the URL-encoded or multipart body.
+func (r *Request) FormValue(name string) string {
+	req, err := r.httpRequest()
+	if err != nil {
+		return ""
+	}
+	return req.FormValue(name)
+}
+
+// FormFile returns the file name and content of the multipart body file field.
+func (r *Request) FormFile(name string) (string, []byte, error) {
+	req, err := r.httpRequest()
+	if err != nil {
+		return "", nil, err
+	}
+	f, h, err := req.FormFile(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	return h.Filename, b, err
+}
+
+func (r *Request) httpRequest() (*http.Request, error) {
+	req, err := http.NewRequest(r.Method, r.Path, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		err = req.ParseMultipartForm(32 << 20)
+	} else {
+		err = req.ParseForm()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openaitest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var chatOptions = &openai.ChatCompletionOptions{
+	Model:    openai.ModelGPT3Dot5Turbo,
+	Messages: []openai.ChatMessage{{Role: openai.RoleUser, Content: "Hello"}},
+}
+
+func TestDefaults(t *testing.T) {
+	e, srv := NewEngine(t)
+	ctx := context.Background()
+
+	chat, err := e.ChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, Text, chat.Choices[0].Message.Content)
+	req := srv.AssertCalled(t, http.MethodPost, "/chat/completions")
+	assert.Equal(t, "Bearer "+APIKey, req.Header.Get("Authorization"))
+	assert.Equal(t, "gpt-3.5-turbo", req.JSON()["model"])
+
+	stream, err := e.StreamChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	var content string
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			break
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	assert.NoError(t, stream.Close())
+	assert.Equal(t, Text, content)
+
+	emb, err := e.Embeddings(ctx, &openai.EmbeddingOptions{
+		Model:          openai.ModelTextEmbeddingAda002,
+		Input:          openai.EmbeddingText("a", "b"),
+		EncodingFormat: openai.EncodingFormatBase64,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, emb.Data, 2)
+	assert.Equal(t, float32(0), openai.DotProduct(emb.Data[0].Embedding, emb.Data[1].Embedding))
+
+	f, err := e.UploadFile(ctx, &openai.UploadFileOptions{
+		File:     strings.NewReader("{}\n"),
+		Filename: "train.jsonl",
+		Purpose:  openai.FilePurposeFineTune,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "train.jsonl", f.Filename)
+	req = srv.AssertCalled(t, http.MethodPost, "/files")
+	assert.Equal(t, "fine-tune", req.FormValue("purpose"))
+	filename, fileContent, err := req.FormFile("file")
+	assert.NoError(t, err)
+	assert.Equal(t, "train.jsonl", filename)
+	assert.Equal(t, "{}\n", string(fileContent))
+
+	img, err := e.ImageCreate(ctx, &openai.ImageCreateOptions{
+		Prompt:         "cat",
+		Size:           openai.SizeSmall,
+		ResponseFormat: openai.ResponseFormatB64Json,
+	})
+	assert.NoError(t, err)
+	b, err := img.Data[0].Bytes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, b)
+
+	job, err := e.RetrieveFineTuningJob(ctx, "ftjob-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ftjob-1", job.Id)
+	assert.True(t, job.Status.Terminal())
+
+	_, err = e.ListModels(ctx)
+	assert.NoError(t, err)
+	srv.AssertCallCount(t, http.MethodGet, "/models", 1)
+	srv.AssertNotCalled(t, http.MethodGet, "/models/*")
+	assert.Len(t, srv.Requests(), 7)
+}
+
+func TestScriptedErrors(t *testing.T) {
+	e, srv := NewEngine(t, openai.WithRetryPolicy(openai.RetryPolicy{
+		MaxAttempts:          3,
+		BaseDelay:            time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+	}))
+	ctx := context.Background()
+
+	srv.Handle(http.MethodPost, "/chat/completions", Sequence(RateLimited(time.Millisecond), ServerError(), Default()))
+	_, err := e.ChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	srv.AssertCallCount(t, http.MethodPost, "/chat/completions", 3)
+
+	srv.Reset()
+	srv.Handle(http.MethodPost, "/chat/completions", Error(http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided"))
+	_, err = e.ChatCompletion(ctx, chatOptions)
+	assert.True(t, errors.Is(err, openai.ErrInvalidAPIKey))
+
+	srv.Handle(http.MethodPost, "/chat/completions", Malformed(http.StatusOK))
+	_, err = e.ChatCompletion(ctx, chatOptions)
+	assert.Error(t, err)
+
+	srv.Handle(http.MethodGet, "/files/*", JSON(http.StatusOK, `{"id":"file-scripted"}`))
+	f, err := e.RetrieveFile(ctx, "file-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "file-scripted", f.Id)
+
+	srv.Handle(http.MethodPost, "/chat/completions", Stream(
+		`{"choices":[{"delta":{"content":"Hi"}}]}`,
+		`{"error":{"message":"Stream interrupted","type":"server_error"}}`,
+	))
+	stream, err := e.StreamChatCompletion(ctx, chatOptions)
+	assert.NoError(t, err)
+	chunk, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi", chunk.Choices[0].Delta.Content)
+	_, err = stream.Recv()
+	var apiErr *openai.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	stream.Close()
+
+	_, err = e.RetrieveModel(ctx, &openai.RetrieveModelOptions{ID: "gpt-4"})
+	assert.NoError(t, err, "unscripted endpoints must be served by default")
+
+	last := srv.LastRequest()
+	assert.Equal(t, "/models/gpt-4", last.Path)
+}
+
+func TestSlow(t *testing.T) {
+	e, srv := NewEngine(t, openai.WithTimeout(50*time.Millisecond))
+	srv.Handle(http.MethodPost, "/chat/completions", Slow(time.Second, Default()))
+	start := time.Now()
+	_, err := e.ChatCompletion(context.Background(), chatOptions)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
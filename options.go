@@ -0,0 +1,72 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option is used to configure the engine in NewWithOptions.
+type Option func(*Engine)
+
+// WithBaseURL sets the base URL of the API. Useful to point the engine at a proxy,
+// an OpenAI-compatible server or a test server.
+//
+// Default: https://api.openai.com/v1
+func WithBaseURL(baseURL string) Option {
+	return func(e *Engine) {
+		e.apiBaseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *Engine) {
+		if client != nil {
+			e.client = client
+		}
+	}
+}
+
+// WithTimeout sets the time limit for requests made by the engine.
+// The HTTP client passed by WithHTTPClient is copied, not modified.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Engine) {
+		e.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with each request.
+func WithUserAgent(userAgent string) Option {
+	return func(e *Engine) {
+		e.userAgent = userAgent
+	}
+}
+
+// WithDefaultHeaders sets headers sent with each request.
+// Authorization, organization and project headers can't be overwritten by them,
+// and Authorization and Api-Key (in Azure mode) headers are never sent from them.
+func WithDefaultHeaders(headers http.Header) Option {
+	return func(e *Engine) {
+		for k, v := range headers {
+			e.headers[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// WithOrganization sets organization ID if user belongs to multiple organizations.
+func WithOrganization(organizationId string) Option {
+	return func(e *Engine) {
+		e.organizationId = organizationId
+	}
+}
+
+// WithProject sets project ID if user has access to multiple projects.
+func WithProject(projectId string) Option {
+	return func(e *Engine) {
+		e.projectId = projectId
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "Bearer test", r.Header.Get("Authorization"))
+		assert.Equal(t, "my-agent/1.0", r.Header.Get("User-Agent"))
+		assert.Equal(t, "org-123", r.Header.Get("OpenAI-Organization"))
+		assert.Equal(t, "proj-123", r.Header.Get("OpenAI-Project"))
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		w.Write([]byte(`{"data":[{"id":"gpt-4","object":"model","owned_by":"openai"}]}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test",
+		WithBaseURL(srv.URL+"/v1/"),
+		WithUserAgent("my-agent/1.0"),
+		WithOrganization("org-123"),
+		WithProject("proj-123"),
+		WithDefaultHeaders(http.Header{
+			"x-custom":      []string{"value"},
+			"Authorization": []string{"Bearer overwritten"},
+		}),
+	)
+	r, err := e.ListModels(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, ModelGPT4, r.Data[0].ID)
+}
+
+func TestWithDefaultHeadersAzure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"azure-key"}, r.Header.Values("Api-Key"))
+		assert.Empty(t, r.Header.Values("Authorization"))
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("azure-key",
+		WithAzure(AzureConfig{Endpoint: srv.URL}),
+		WithDefaultHeaders(http.Header{
+			"x-custom":      []string{"value"},
+			"Authorization": []string{"Bearer leaked"},
+			"api-key":       []string{"overwritten"},
+		}),
+	)
+	_, err := e.ListModels(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestWithTimeout(t *testing.T) {
+	client := &http.Client{}
+	e := NewWithOptions("test", WithHTTPClient(client), WithTimeout(5*time.Second))
+	assert.Equal(t, 5*time.Second, e.client.Timeout)
+	assert.Zero(t, client.Timeout, "client passed by user must not be modified")
+
+	e = New("test")
+	assert.Equal(t, defaultBaseURL, e.apiBaseURL)
+	assert.Zero(t, e.client.Timeout)
+}
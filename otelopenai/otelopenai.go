@@ -0,0 +1,566 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package otelopenai instruments openai.Engine with OpenTelemetry tracing and metrics
+// following the GenAI semantic conventions.
+//
+// It's a separate module, so the openai package doesn't depend on OpenTelemetry:
+//
+//	e := openai.NewWithOptions(os.Getenv("OPENAI_KEY"), otelopenai.Instrument())
+//
+// Learn more: https://opentelemetry.io/docs/specs/semconv/gen-ai/
+package otelopenai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/0x9ef/openai-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of tracer and meter.
+const ScopeName = "github.com/0x9ef/openai-go/otelopenai"
+
+const (
+	systemOpenAI = "openai"
+
+	attrOperationName         = attribute.Key("gen_ai.operation.name")
+	attrSystem                = attribute.Key("gen_ai.system")
+	attrRequestModel          = attribute.Key("gen_ai.request.model")
+	attrRequestMaxTokens      = attribute.Key("gen_ai.request.max_tokens")
+	attrRequestTemperature    = attribute.Key("gen_ai.request.temperature")
+	attrRequestTopP           = attribute.Key("gen_ai.request.top_p")
+	attrRequestChoiceCount    = attribute.Key("gen_ai.request.choice.count")
+	attrResponseId            = attribute.Key("gen_ai.response.id")
+	attrResponseModel         = attribute.Key("gen_ai.response.model")
+	attrResponseFinishReasons = attribute.Key("gen_ai.response.finish_reasons")
+	attrUsageInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	attrUsageOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+	attrTokenType             = attribute.Key("gen_ai.token.type")
+	attrServerAddress         = attribute.Key("server.address")
+	attrServerPort            = attribute.Key("server.port")
+	attrStatusCode            = attribute.Key("http.response.status_code")
+	attrErrorType             = attribute.Key("error.type")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider. By default the global one is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider. By default the global one is used.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// Instrument returns the engine option which records spans and metrics for each API request.
+func Instrument(opts ...Option) openai.Option {
+	return openai.WithMiddleware(Middleware(opts...))
+}
+
+// Middleware returns the engine middleware which records spans and metrics for each API request.
+// Retried requests are recorded as separate spans.
+func Middleware(opts ...Option) openai.Middleware {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	meter := c.meterProvider.Meter(ScopeName)
+	inst := &instrumentation{
+		tracer: c.tracerProvider.Tracer(ScopeName),
+	}
+	var err error
+	inst.duration, err = meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithDescription("GenAI operation duration."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	inst.tokenUsage, err = meter.Int64Histogram("gen_ai.client.token.usage",
+		metric.WithDescription("Measures number of input and output tokens used."),
+		metric.WithUnit("{token}"),
+		metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return inst.middleware
+}
+
+type instrumentation struct {
+	tracer     trace.Tracer
+	duration   metric.Float64Histogram
+	tokenUsage metric.Int64Histogram
+}
+
+func (inst *instrumentation) middleware(next openai.Handler) openai.Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		call := newCall(req)
+		ctx, span := inst.tracer.Start(req.Context(), call.spanName(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(call.requestAttrs...),
+		)
+		call.ctx, call.span, call.start = ctx, span, time.Now()
+		call.inst = inst
+
+		resp, err := next(req.WithContext(ctx))
+		if err != nil {
+			call.end(nil, err)
+			return resp, err
+		}
+		contentType := resp.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "text/event-stream"):
+			resp.Body = &streamBody{ReadCloser: resp.Body, call: call, resp: resp}
+		case strings.HasPrefix(contentType, "application/json"):
+			resp.Body = newJSONBody(resp.Body, call, resp)
+		default:
+			// File content, audio and subtitles have no usage
+			call.end(resp, nil)
+		}
+		return resp, nil
+	}
+}
+
+// call is the instrumented API request.
+type call struct {
+	inst         *instrumentation
+	ctx          context.Context
+	span         trace.Span
+	start        time.Time
+	operation    string
+	model        string
+	requestAttrs []attribute.KeyValue
+	result       result
+	once         sync.Once
+}
+
+func newCall(req *http.Request) *call {
+	c := &call{
+		operation: operationName(req.URL.Path),
+	}
+	c.requestAttrs = append(c.requestAttrs,
+		attrOperationName.String(c.operation),
+		attrSystem.String(systemOpenAI),
+	)
+	host, port := req.URL.Hostname(), req.URL.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[req.URL.Scheme]
+	}
+	c.requestAttrs = append(c.requestAttrs, attrServerAddress.String(host))
+	if p, err := strconv.Atoi(port); err == nil {
+		c.requestAttrs = append(c.requestAttrs, attrServerPort.Int(p))
+	}
+
+	// Only JSON bodies are decoded, multipart bodies may be streamed and can't be read twice
+	if req.GetBody == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		return c
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return c
+	}
+	defer body.Close()
+	var v struct {
+		Model       string   `json:"model"`
+		MaxTokens   *int     `json:"max_tokens"`
+		Temperature *float64 `json:"temperature"`
+		TopP        *float64 `json:"top_p"`
+		N           *int     `json:"n"`
+	}
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
+		return c
+	}
+	c.model = v.Model
+	if v.Model != "" {
+		c.requestAttrs = append(c.requestAttrs, attrRequestModel.String(v.Model))
+	}
+	if v.MaxTokens != nil {
+		c.requestAttrs = append(c.requestAttrs, attrRequestMaxTokens.Int(*v.MaxTokens))
+	}
+	if v.Temperature != nil {
+		c.requestAttrs = append(c.requestAttrs, attrRequestTemperature.Float64(*v.Temperature))
+	}
+	if v.TopP != nil {
+		c.requestAttrs = append(c.requestAttrs, attrRequestTopP.Float64(*v.TopP))
+	}
+	if v.N != nil && *v.N != 1 {
+		c.requestAttrs = append(c.requestAttrs, attrRequestChoiceCount.Int(*v.N))
+	}
+	return c
+}
+
+func (c *call) spanName() string {
+	if c.model == "" {
+		return c.operation
+	}
+	return c.operation + " " + c.model
+}
+
+// end finishes the span and records metrics. It's called once.
+func (c *call) end(resp *http.Response, err error) {
+	c.once.Do(func() {
+		elapsed := time.Since(c.start)
+		metricAttrs := []attribute.KeyValue{
+			attrOperationName.String(c.operation),
+			attrSystem.String(systemOpenAI),
+		}
+		for _, kv := range c.requestAttrs {
+			if kv.Key == attrRequestModel || kv.Key == attrServerAddress || kv.Key == attrServerPort {
+				metricAttrs = append(metricAttrs, kv)
+			}
+		}
+		var attrs []attribute.KeyValue
+		if resp != nil {
+			attrs = append(attrs, attrStatusCode.Int(resp.StatusCode))
+		}
+		r := c.result
+		if r.Id != "" {
+			attrs = append(attrs, attrResponseId.String(r.Id))
+		}
+		if r.Model != "" {
+			attrs = append(attrs, attrResponseModel.String(r.Model))
+			metricAttrs = append(metricAttrs, attrResponseModel.String(r.Model))
+		}
+		if len(r.finishReasons) != 0 {
+			attrs = append(attrs, attrResponseFinishReasons.StringSlice(r.finishReasons))
+		}
+		if r.Usage != nil {
+			attrs = append(attrs,
+				attrUsageInputTokens.Int(r.Usage.PromptTokens),
+				attrUsageOutputTokens.Int(r.Usage.CompletionTokens),
+			)
+		}
+
+		errorType := ""
+		switch {
+		case err != nil:
+			errorType = errorTypeOf(err)
+			c.span.RecordError(err)
+			c.span.SetStatus(codes.Error, err.Error())
+		case resp.StatusCode >= 400:
+			errorType = strconv.Itoa(resp.StatusCode)
+			c.span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+		if errorType != "" {
+			attrs = append(attrs, attrErrorType.String(errorType))
+			metricAttrs = append(metricAttrs, attrErrorType.String(errorType))
+		}
+		c.span.SetAttributes(attrs...)
+		c.span.End()
+
+		// Context of the caller is used, so metrics are recorded even if the request was canceled
+		ctx := context.WithoutCancel(c.ctx)
+		if c.inst.duration != nil {
+			c.inst.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(metricAttrs...))
+		}
+		if c.inst.tokenUsage != nil && r.Usage != nil && errorType == "" {
+			c.inst.tokenUsage.Record(ctx, int64(r.Usage.PromptTokens),
+				metric.WithAttributes(append(metricAttrs, attrTokenType.String("input"))...))
+			if r.Usage.CompletionTokens != 0 || c.operation != "embeddings" {
+				c.inst.tokenUsage.Record(ctx, int64(r.Usage.CompletionTokens),
+					metric.WithAttributes(append(metricAttrs, attrTokenType.String("output"))...))
+			}
+		}
+	})
+}
+
+func errorTypeOf(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return fmt.Sprintf("%T", err)
+	}
+}
+
+// operationName returns the GenAI operation name of the API endpoint.
+func operationName(path string) string {
+	for _, op := range operations {
+		if strings.HasSuffix(path, op.suffix) {
+			return op.name
+		}
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, s := range segments {
+		switch s {
+		case "files", "fine_tuning", "models":
+			return s
+		}
+	}
+	return "unknown"
+}
+
+var operations = []struct {
+	suffix string
+	name   string
+}{
+	{"/chat/completions", "chat"},
+	{"/completions", "text_completion"},
+	{"/embeddings", "embeddings"},
+	{"/edits", "edit"},
+	{"/moderations", "moderation"},
+	{"/images/generations", "image_generation"},
+	{"/images/edits", "image_edit"},
+	{"/images/variations", "image_variation"},
+	{"/audio/transcriptions", "transcription"},
+	{"/audio/translations", "translation"},
+}
+
+// result accumulates response fields from the response body or stream chunks.
+type result struct {
+	Id      string        `json:"id"`
+	Model   string        `json:"model"`
+	Usage   *openai.Usage `json:"usage"`
+	Choices []choice      `json:"choices"`
+
+	finishReasons []string
+}
+
+type choice struct {
+	Index        int    `json:"index"`
+	FinishReason string `json:"finish_reason"`
+}
+
+func (r *result) merge(data []byte) {
+	var chunk result
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+	if chunk.Id != "" {
+		r.Id = chunk.Id
+	}
+	if chunk.Model != "" {
+		r.Model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		r.Usage = chunk.Usage
+	}
+	for _, c := range chunk.Choices {
+		r.addFinishReason(c)
+	}
+}
+
+func (r *result) addFinishReason(c choice) {
+	if c.FinishReason == "" {
+		return
+	}
+	for len(r.finishReasons) <= c.Index {
+		r.finishReasons = append(r.finishReasons, "")
+	}
+	r.finishReasons[c.Index] = c.FinishReason
+}
+
+// decode reads the response object token by token, so large values, e.g. embeddings,
+// are skipped without buffering the whole response.
+func (r *result) decode(rd io.Reader) error {
+	dec := json.NewDecoder(rd)
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			err = dec.Decode(&r.Id)
+		case "model":
+			err = dec.Decode(&r.Model)
+		case "usage":
+			err = dec.Decode(&r.Usage)
+		case "choices":
+			err = decodeArray(dec, func() error {
+				var c choice
+				if err := dec.Decode(&c); err != nil {
+					return err
+				}
+				r.addFinishReason(c)
+				return nil
+			})
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeArray calls decodeElem for each element of the array, or skips the value if it's not an array.
+func decodeArray(dec *json.Decoder, decodeElem func() error) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != json.Delim('[') {
+		return skipRest(dec, t)
+	}
+	for dec.More() {
+		if err := decodeElem(); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// skipValue skips the next value.
+func skipValue(dec *json.Decoder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	return skipRest(dec, t)
+}
+
+// skipRest skips the rest of the value started by token t.
+func skipRest(dec *json.Decoder, t json.Token) error {
+	depth := 0
+	for {
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+		var err error
+		if t, err = dec.Token(); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonBody parses the JSON response while it's read by the engine, so it stays streamed,
+// and ends the span when the body is read or closed.
+type jsonBody struct {
+	io.ReadCloser
+	call *call
+	resp *http.Response
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+func newJSONBody(body io.ReadCloser, c *call, resp *http.Response) *jsonBody {
+	pr, pw := io.Pipe()
+	b := &jsonBody{ReadCloser: body, call: c, resp: resp, pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(b.done)
+		c.result.decode(pr)
+		// Unblock writes if decoding stopped before the end of body
+		pr.Close()
+	}()
+	return b
+}
+
+func (b *jsonBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		// Write fails only if decoding stopped
+		b.pw.Write(p[:n])
+	}
+	switch {
+	case err == io.EOF:
+		b.finish(nil)
+	case err != nil:
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *jsonBody) Close() error {
+	b.finish(nil)
+	return b.ReadCloser.Close()
+}
+
+func (b *jsonBody) finish(err error) {
+	b.pw.Close()
+	<-b.done
+	b.call.end(b.resp, err)
+}
+
+var sseDataPrefix = []byte("data:")
+
+// streamBody parses Server-Sent Events while they are read by the engine
+// and ends the span when the stream is finished or closed.
+type streamBody struct {
+	io.ReadCloser
+	call *call
+	resp *http.Response
+	line []byte
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.scan(p[:n])
+	switch {
+	case err == io.EOF:
+		b.call.end(b.resp, nil)
+	case err != nil:
+		b.call.end(b.resp, err)
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	b.call.end(b.resp, nil)
+	return b.ReadCloser.Close()
+}
+
+func (b *streamBody) scan(p []byte) {
+	b.line = append(b.line, p...)
+	for {
+		i := bytes.IndexByte(b.line, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimRight(b.line[:i], "\r")
+		if bytes.HasPrefix(line, sseDataPrefix) {
+			b.call.result.merge(bytes.TrimSpace(line[len(sseDataPrefix):]))
+		}
+		b.line = b.line[i+1:]
+	}
+}
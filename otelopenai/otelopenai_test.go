@@ -0,0 +1,194 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package otelopenai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	openai "github.com/0x9ef/openai-go"
+	"github.com/0x9ef/openai-go/openaitest"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestEngine(t *testing.T) (*openai.Engine, *openaitest.Server, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	e, srv := openaitest.NewEngine(t, Instrument(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	))
+	return e, srv, exporter, reader
+}
+
+func spanAttrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+var chatOptions = &openai.ChatCompletionOptions{
+	Model:       openai.ModelGPT4,
+	Messages:    []openai.ChatMessage{{Role: openai.RoleUser, Content: "Hello"}},
+	MaxTokens:   100,
+	Temperature: 0.5,
+}
+
+func TestChatCompletion(t *testing.T) {
+	e, _, exporter, reader := newTestEngine(t)
+	_, err := e.ChatCompletion(context.Background(), chatOptions)
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	assert.Equal(t, "chat gpt-4", spans[0].Name)
+	attrs := spanAttrs(spans[0])
+	assert.Equal(t, "chat", attrs[attrOperationName].AsString())
+	assert.Equal(t, "openai", attrs[attrSystem].AsString())
+	assert.Equal(t, "gpt-4", attrs[attrRequestModel].AsString())
+	assert.Equal(t, int64(100), attrs[attrRequestMaxTokens].AsInt64())
+	assert.Equal(t, 0.5, attrs[attrRequestTemperature].AsFloat64())
+	assert.Equal(t, "chatcmpl-test", attrs[attrResponseId].AsString())
+	assert.Equal(t, []string{"stop"}, attrs[attrResponseFinishReasons].AsStringSlice())
+	assert.Equal(t, int64(5), attrs[attrUsageInputTokens].AsInt64())
+	assert.Equal(t, int64(5), attrs[attrUsageOutputTokens].AsInt64())
+	assert.Equal(t, int64(200), attrs[attrStatusCode].AsInt64())
+
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	duration := metrics["gen_ai.client.operation.duration"].(metricdata.Histogram[float64])
+	assert.Equal(t, uint64(1), duration.DataPoints[0].Count)
+	usage := metrics["gen_ai.client.token.usage"].(metricdata.Histogram[int64])
+	assert.Len(t, usage.DataPoints, 2)
+	for _, dp := range usage.DataPoints {
+		assert.Equal(t, int64(5), dp.Sum)
+	}
+}
+
+func TestStreamChatCompletion(t *testing.T) {
+	e, _, exporter, _ := newTestEngine(t)
+	stream, err := e.StreamChatCompletion(context.Background(), chatOptions)
+	assert.NoError(t, err)
+	assert.Empty(t, exporter.GetSpans(), "span must end with the stream")
+	for {
+		if _, err := stream.Recv(); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+	assert.NoError(t, stream.Close())
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		attrs := spanAttrs(spans[0])
+		assert.Equal(t, []string{"stop"}, attrs[attrResponseFinishReasons].AsStringSlice())
+		assert.Equal(t, "gpt-4", attrs[attrResponseModel].AsString())
+	}
+}
+
+func TestError(t *testing.T) {
+	e, srv, exporter, _ := newTestEngine(t)
+	srv.Handle(http.MethodPost, "/embeddings", openaitest.RateLimited(0))
+	_, err := e.Embeddings(context.Background(), &openai.EmbeddingOptions{
+		Model: openai.ModelTextEmbeddingAda002,
+		Input: openai.EmbeddingText("Hello"),
+	})
+	assert.ErrorIs(t, err, openai.ErrRateLimited)
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "embeddings text-embedding-ada-002", spans[0].Name)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		attrs := spanAttrs(spans[0])
+		assert.Equal(t, "429", attrs[attrErrorType].AsString())
+	}
+
+	exporter.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = e.ListModels(ctx)
+	assert.Error(t, err)
+	spans = exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "models", spans[0].Name)
+		assert.Equal(t, "canceled", spanAttrs(spans[0])[attrErrorType].AsString())
+	}
+}
+
+// countingReader counts bytes read from the response body.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestResponseBodyStreamed(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	middleware := Middleware(WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))))
+	respond := func(contentType string, body *countingReader) openai.Handler {
+		return middleware(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{contentType}},
+				Body:       io.NopCloser(body),
+				Request:    req,
+			}, nil
+		})
+	}
+
+	// Usage is read from the end of the large response, while it's decoded by the caller
+	embedding := strings.Repeat("0.0123456789,", 100000)
+	body := &countingReader{r: strings.NewReader(`{"object":"list","data":[{"embedding":[` + embedding +
+		`1]}],"model":"text-embedding-ada-002","usage":{"prompt_tokens":7,"total_tokens":7}}`)}
+	req, _ := http.NewRequest(http.MethodPost, "https://api.openai.com/v1/embeddings", nil)
+	resp, err := respond("application/json", body)(req)
+	assert.NoError(t, err)
+	assert.Zero(t, body.n, "body must not be read by the middleware")
+	var v openai.EmbeddingResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+	assert.NoError(t, resp.Body.Close())
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		attrs := spanAttrs(spans[0])
+		assert.Equal(t, int64(7), attrs[attrUsageInputTokens].AsInt64())
+		assert.Equal(t, "text-embedding-ada-002", attrs[attrResponseModel].AsString())
+	}
+
+	// File content is not parsed
+	exporter.Reset()
+	body = &countingReader{r: strings.NewReader(`{"prompt":"Hello","completion":"Hi"}` + "\n")}
+	req, _ = http.NewRequest(http.MethodGet, "https://api.openai.com/v1/files/file-1/content", nil)
+	resp, err = respond("application/octet-stream", body)(req)
+	assert.NoError(t, err)
+	assert.Zero(t, body.n)
+	assert.Len(t, exporter.GetSpans(), 1)
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"prompt":"Hello","completion":"Hi"}`+"\n", string(b))
+}
@@ -0,0 +1,363 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x9ef/openai-go/tokenizer"
+)
+
+// RateLimit is the budget of requests and tokens per minute.
+// Zero values mean no limit.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimiter blocks requests exceeding requests and tokens per minute limits of the model.
+// Tokens of the request are estimated as the number of prompt tokens plus max_tokens.
+// Each retry of the request takes a request, but its tokens are taken once.
+//
+// The limiter adapts to limits reported by the API in x-ratelimit-* response headers,
+// so it also accounts for requests made by other clients using the same API key.
+// It is safe for concurrent use and can be shared by multiple engines.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	limits  map[Model]RateLimit
+	buckets map[Model]*rateBuckets
+	now     func() time.Time
+}
+
+// NewRateLimiter creates the limiter with the default limit applied to each model separately.
+// If the limit is zero, limits are learned from response headers.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		limits:  make(map[Model]RateLimit),
+		buckets: make(map[Model]*rateBuckets),
+		now:     time.Now,
+	}
+}
+
+// SetModelLimit overrides the default limit for the model.
+func (l *RateLimiter) SetModelLimit(model Model, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[model] = limit
+	if b, ok := l.buckets[model]; ok {
+		b.requests.setLimit(float64(limit.RequestsPerMinute))
+		b.tokens.setLimit(float64(limit.TokensPerMinute))
+	}
+}
+
+// WithRateLimiter sets the limiter used to wait before sending requests.
+// By default requests are not limited.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(e *Engine) {
+		e.rateLimiter = l
+	}
+}
+
+// Wait blocks until the request with the given number of tokens can be sent to the model
+// without exceeding its limits, or until ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, model Model, tokens int) error {
+	for {
+		l.mu.Lock()
+		b := l.bucketsFor(model)
+		now := l.now()
+		b.requests.refill(now)
+		b.tokens.refill(now)
+		d := b.requests.wait(1, now)
+		if td := b.tokens.wait(float64(tokens), now); td > d {
+			d = td
+		}
+		if d <= 0 {
+			b.requests.take(1)
+			b.tokens.take(float64(tokens))
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// update adjusts limits of the model using x-ratelimit-* response headers.
+func (l *RateLimiter) update(model Model, h http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucketsFor(model)
+	now := l.now()
+	b.requests.update(now, h.Get("X-Ratelimit-Limit-Requests"), h.Get("X-Ratelimit-Remaining-Requests"), h.Get("X-Ratelimit-Reset-Requests"))
+	b.tokens.update(now, h.Get("X-Ratelimit-Limit-Tokens"), h.Get("X-Ratelimit-Remaining-Tokens"), h.Get("X-Ratelimit-Reset-Tokens"))
+}
+
+func (l *RateLimiter) bucketsFor(model Model) *rateBuckets {
+	b, ok := l.buckets[model]
+	if !ok {
+		limit, ok := l.limits[model]
+		if !ok {
+			limit = l.limit
+		}
+		now := l.now()
+		b = &rateBuckets{
+			requests: newRateBucket(float64(limit.RequestsPerMinute), now),
+			tokens:   newRateBucket(float64(limit.TokensPerMinute), now),
+		}
+		l.buckets[model] = b
+	}
+	return b
+}
+
+type rateBuckets struct {
+	requests *rateBucket
+	tokens   *rateBucket
+}
+
+// rateBucket is the token bucket which is refilled continuously up to limit per minute.
+type rateBucket struct {
+	limit        float64
+	available    float64
+	updated      time.Time
+	blockedUntil time.Time
+}
+
+func newRateBucket(limit float64, now time.Time) *rateBucket {
+	return &rateBucket{limit: limit, available: limit, updated: now}
+}
+
+func (b *rateBucket) setLimit(limit float64) {
+	if b.available > limit {
+		b.available = limit
+	}
+	b.limit = limit
+}
+
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.available += b.limit * elapsed.Minutes()
+		if b.available > b.limit {
+			b.available = b.limit
+		}
+		b.updated = now
+	}
+}
+
+// wait returns how long to wait until n units are available.
+func (b *rateBucket) wait(n float64, now time.Time) time.Duration {
+	if b.limit == 0 {
+		return 0
+	}
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	if n > b.limit {
+		// Request larger than the whole budget waits for the full bucket
+		n = b.limit
+	}
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.limit * float64(time.Minute))
+}
+
+func (b *rateBucket) take(n float64) {
+	if b.limit != 0 {
+		b.available -= n
+	}
+}
+
+// update adjusts the bucket using limit, remaining and reset values reported by the API.
+func (b *rateBucket) update(now time.Time, limit, remaining, reset string) {
+	if n, err := strconv.ParseFloat(limit, 64); err == nil && n > 0 && b.limit == 0 {
+		b.limit = n
+		b.available = n
+		b.updated = now
+	}
+	if b.limit == 0 {
+		return
+	}
+	n, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return
+	}
+	if n < b.available {
+		b.available = n
+	}
+	if n <= 0 {
+		if d, err := time.ParseDuration(reset); err == nil {
+			b.blockedUntil = now.Add(d)
+		}
+	}
+}
+
+// requestInfo describes the API request.
+type requestInfo struct {
+	// API endpoint, e.g. /chat/completions.
+	endpoint string
+	model    Model
+	// Estimated number of prompt tokens plus max_tokens.
+	tokens int
+}
+
+// newRequestInfo returns information about the request.
+// The JSON body is decoded to get the model and estimate tokens only if estimate is true,
+// so requests are not read twice when no rate limiter needs them.
+func (e *Engine) newRequestInfo(req *http.Request, estimate bool) requestInfo {
+	info := requestInfo{
+		endpoint: e.endpointPath(req.URL),
+	}
+	if !estimate || !hasJSONBody(req) {
+		return info
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return info
+	}
+	defer body.Close()
+	var v struct {
+		Model       Model           `json:"model"`
+		MaxTokens   int             `json:"max_tokens"`
+		N           int             `json:"n"`
+		BestOf      int             `json:"best_of"`
+		Prompt      json.RawMessage `json:"prompt"`
+		Input       json.RawMessage `json:"input"`
+		Instruction string          `json:"instruction"`
+		Messages    []struct {
+			Role    string `json:"role"`
+			Name    string `json:"name"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 10<<20)).Decode(&v); err != nil {
+		return info
+	}
+	info.model = v.Model
+	tokens := estimateTokens(v.Model, v.Instruction)
+	tokens += promptTokens(v.Model, v.Prompt)
+	tokens += promptTokens(v.Model, v.Input)
+	if len(v.Messages) != 0 {
+		messages := make([]tokenizer.Message, len(v.Messages))
+		for i, m := range v.Messages {
+			messages[i] = tokenizer.Message{Role: m.Role, Name: m.Name, Content: m.Content}
+		}
+		if n, err := tokenizer.CountMessageTokens(string(v.Model), messages); err == nil {
+			tokens += n
+		} else {
+			for _, m := range messages {
+				tokens += 4 + estimateTokens(v.Model, m.Content)
+			}
+		}
+	}
+	n := v.N
+	if v.BestOf > n {
+		// All best_of completions are generated and count against the limit
+		n = v.BestOf
+	}
+	if n == 0 {
+		n = 1
+	}
+	info.tokens = tokens + v.MaxTokens*n
+	return info
+}
+
+func hasJSONBody(req *http.Request) bool {
+	return req.GetBody != nil && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
+}
+
+// requestModel returns the model of the JSON request. Only the body up to the model field is decoded,
+// which is the first field of request options.
+func requestModel(req *http.Request) Model {
+	if !hasJSONBody(req) {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	dec := json.NewDecoder(io.LimitReader(body, 10<<20))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key == "model" {
+			var model Model
+			if err := dec.Decode(&model); err != nil {
+				return ""
+			}
+			return model
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// estimateTokens counts tokens of texts using the model tokenizer,
+// or estimates them as 4 characters per token if the tokenizer is not available.
+func estimateTokens(model Model, texts ...string) int {
+	var n int
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		if count, err := tokenizer.CountTokens(string(model), text); err == nil {
+			n += count
+		} else {
+			n += (len(text) + 3) / 4
+		}
+	}
+	return n
+}
+
+// promptTokens estimates tokens of the JSON string, array of strings, array of tokens or array of token arrays.
+func promptTokens(model Model, raw json.RawMessage) int {
+	if len(raw) == 0 {
+		return 0
+	}
+	var texts []string
+	if err := json.Unmarshal(raw, &texts); err == nil {
+		return estimateTokens(model, texts...)
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return estimateTokens(model, text)
+	}
+	var tokens []int
+	if err := json.Unmarshal(raw, &tokens); err == nil {
+		return len(tokens)
+	}
+	var arrays [][]int
+	if err := json.Unmarshal(raw, &arrays); err == nil {
+		var n int
+		for _, a := range arrays {
+			n += len(a)
+		}
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(RateLimit{RequestsPerMinute: 1})
+	l.SetModelLimit(ModelGPT4, RateLimit{TokensPerMinute: 100})
+	ctx := context.Background()
+
+	assert.NoError(t, l.Wait(ctx, ModelGPT3Dot5Turbo, 1000))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(timeoutCtx, ModelGPT3Dot5Turbo, 1), context.DeadlineExceeded)
+
+	// Models have separate budgets
+	assert.NoError(t, l.Wait(ctx, ModelGPT4, 80))
+	assert.NoError(t, l.Wait(ctx, ModelGPT4, 20))
+	assert.ErrorIs(t, l.Wait(timeoutCtx, ModelGPT4, 10), context.DeadlineExceeded)
+
+	// Bucket is refilled continuously
+	now := time.Now()
+	l.now = func() time.Time { return now.Add(6 * time.Second) }
+	assert.NoError(t, l.Wait(ctx, ModelGPT4, 10))
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-Ratelimit-Limit-Requests", "3")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+		w.Header().Set("X-Ratelimit-Reset-Requests", "1m0s")
+		w.Header().Set("X-Ratelimit-Limit-Tokens", "40000")
+		w.Header().Set("X-Ratelimit-Remaining-Tokens", "39000")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer srv.Close()
+
+	l := NewRateLimiter(RateLimit{})
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRateLimiter(l))
+	opts := &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+	}
+	_, err := e.ChatCompletion(context.Background(), opts)
+	assert.NoError(t, err)
+	b := l.buckets[ModelGPT3Dot5Turbo]
+	assert.Equal(t, float64(3), b.requests.limit)
+	assert.Equal(t, float64(40000), b.tokens.limit)
+
+	// Remaining requests are exhausted until reset
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = e.ChatCompletion(ctx, opts)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRateLimiterRetry(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer srv.Close()
+
+	l := NewRateLimiter(RateLimit{RequestsPerMinute: 100, TokensPerMinute: 10000})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRateLimiter(l), WithRetryPolicy(testRetryPolicy))
+	_, err := e.ChatCompletion(context.Background(), &ChatCompletionOptions{
+		Model:     ModelGPT3Dot5Turbo,
+		Messages:  []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+		MaxTokens: 1000,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// Every attempt takes a request, but tokens are taken once
+	b := l.buckets[ModelGPT3Dot5Turbo]
+	assert.Equal(t, float64(97), b.requests.available)
+	spent := 10000 - b.tokens.available
+	assert.GreaterOrEqual(t, spent, float64(1000))
+	assert.Less(t, spent, float64(1100))
+}
+
+func TestRequestInfo(t *testing.T) {
+	e := NewWithOptions("test", WithBaseURL("http://localhost/v1"))
+	body, err := marshalJson(&ChatCompletionOptions{
+		Model:     ModelGPT4,
+		Messages:  []ChatMessage{{Role: RoleUser, Content: "Hello, how are you?"}},
+		MaxTokens: 100,
+		N:         2,
+	})
+	assert.NoError(t, err)
+	req, err := e.newReq(context.Background(), http.MethodPost, e.apiBaseURL+"/chat/completions", "json", body)
+	assert.NoError(t, err)
+	info := e.newRequestInfo(req, true)
+	assert.Equal(t, "/chat/completions", info.endpoint)
+	assert.Equal(t, ModelGPT4, info.model)
+	// 3 tokens per message, 1 of the role and 6 of the content, 3 of the reply and 2 * 100 of completions
+	assert.Equal(t, 213, info.tokens)
+
+	body, err = marshalJson(map[string]interface{}{"model": "text-davinci-003", "prompt": [][]int{{1, 2, 3}, {4}}, "max_tokens": 10})
+	assert.NoError(t, err)
+	req, err = e.newReq(context.Background(), http.MethodPost, e.apiBaseURL+"/completions", "json", body)
+	assert.NoError(t, err)
+	assert.Equal(t, 14, e.newRequestInfo(req, true).tokens)
+
+	req, err = e.newReq(context.Background(), http.MethodGet, e.apiBaseURL+"/files?limit=1", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, requestInfo{endpoint: "/files"}, e.newRequestInfo(req, true))
+
+	// Body is not read if tokens are not estimated
+	req, err = e.newReq(context.Background(), http.MethodPost, e.apiBaseURL+"/chat/completions", "json", body)
+	assert.NoError(t, err)
+	req.GetBody = func() (io.ReadCloser, error) {
+		t.Error("body must not be read")
+		return nil, errors.New("body must not be read")
+	}
+	assert.Equal(t, requestInfo{endpoint: "/chat/completions"}, e.newRequestInfo(req, false))
+
+	body, err = marshalJson(&EmbeddingOptions{Model: ModelTextEmbeddingAda002, Input: EmbeddingText("Hello")})
+	assert.NoError(t, err)
+	req, err = e.newReq(context.Background(), http.MethodPost, e.apiBaseURL+"/embeddings", "json", body)
+	assert.NoError(t, err)
+	assert.Equal(t, ModelTextEmbeddingAda002, requestModel(req))
+}
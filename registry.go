@@ -0,0 +1,213 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x9ef/openai-go/tokenizer"
+)
+
+// Endpoint represents the API endpoint a model can be used with.
+type Endpoint string
+
+const (
+	EndpointCompletions         Endpoint = "/completions"
+	EndpointChatCompletions     Endpoint = "/chat/completions"
+	EndpointEdits               Endpoint = "/edits"
+	EndpointEmbeddings          Endpoint = "/embeddings"
+	EndpointAudioTranscriptions Endpoint = "/audio/transcriptions"
+	EndpointAudioTranslations   Endpoint = "/audio/translations"
+	EndpointFineTuning          Endpoint = "/fine_tuning/jobs"
+	EndpointImageGenerations    Endpoint = "/images/generations"
+	EndpointImageEdits          Endpoint = "/images/edits"
+	EndpointImageVariations     Endpoint = "/images/variations"
+)
+
+// ErrIncompatibleModel is returned when the model can't be used with the endpoint.
+var ErrIncompatibleModel = errors.New("model is not compatible with endpoint")
+
+// Pricing represents the price of the model in USD.
+type Pricing struct {
+	// Price of 1K prompt tokens.
+	PromptPer1K float64
+	// Price of 1K completion tokens.
+	CompletionPer1K float64
+	// Price of a minute of audio.
+	AudioPerMinute float64
+}
+
+// ModelInfo describes capabilities and limits of the model.
+type ModelInfo struct {
+	// ID of the model.
+	ID Model
+	// The maximum number of tokens in the prompt plus the completion.
+	ContextLength int
+	// The maximum number of tokens the model can generate.
+	MaxOutputTokens int
+	// Endpoints the model can be used with.
+	// If empty, the model is unknown and can be used with any endpoint.
+	Endpoints []Endpoint
+	// Name of the tokenizer encoding used by the model.
+	Encoding string
+	// Date when the model is shut down, zero if the model is not deprecated.
+	DeprecationDate time.Time
+	// Price of the model usage.
+	Pricing Pricing
+}
+
+// SupportsEndpoint reports whether the model can be used with the endpoint.
+// Unknown models are supported by all endpoints.
+func (m ModelInfo) SupportsEndpoint(endpoint Endpoint) bool {
+	if len(m.Endpoints) == 0 {
+		return true
+	}
+	for _, ep := range m.Endpoints {
+		if ep == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Deprecated reports whether the model is shut down at the moment t.
+func (m ModelInfo) Deprecated(t time.Time) bool {
+	return !m.DeprecationDate.IsZero() && !t.Before(m.DeprecationDate)
+}
+
+// Cost returns the price of the token usage in USD.
+func (m ModelInfo) Cost(usage Usage) float64 {
+	return float64(usage.PromptTokens)/1000*m.Pricing.PromptPer1K +
+		float64(usage.CompletionTokens)/1000*m.Pricing.CompletionPer1K
+}
+
+var (
+	completionEndpoints = []Endpoint{EndpointCompletions}
+	chatEndpoints       = []Endpoint{EndpointChatCompletions}
+	audioEndpoints      = []Endpoint{EndpointAudioTranscriptions, EndpointAudioTranslations}
+	shutdown2024        = time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+	shutdown2024June    = time.Date(2024, time.June, 13, 0, 0, 0, 0, time.UTC)
+)
+
+var (
+	modelsMu sync.RWMutex
+	models   = map[Model]ModelInfo{
+		// Codex
+		ModelCodexDavinci002: {ContextLength: 8001, MaxOutputTokens: 8001, Endpoints: completionEndpoints, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024},
+		ModelCodexCushman001: {ContextLength: 2048, MaxOutputTokens: 2048, Endpoints: completionEndpoints, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024},
+		// GPT-3
+		ModelGPT3Ada: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.0004, CompletionPer1K: 0.0004}},
+		ModelGPT3Babbage: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.0005, CompletionPer1K: 0.0005}},
+		ModelGPT3Curie: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.002, CompletionPer1K: 0.002}},
+		ModelGPT3Davince: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.02, CompletionPer1K: 0.02}},
+		ModelGPT3TextAda001: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.0004, CompletionPer1K: 0.0004}},
+		ModelGPT3TextBabbage: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.0005, CompletionPer1K: 0.0005}},
+		ModelGPT3TextCurie001: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.002, CompletionPer1K: 0.002}},
+		ModelGPT3TextDavince: {ContextLength: 2049, MaxOutputTokens: 2049, Endpoints: completionEndpoints, Encoding: tokenizer.R50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.02, CompletionPer1K: 0.02}},
+		ModelGPT3TextDavinci002: {ContextLength: 4097, MaxOutputTokens: 4097, Endpoints: completionEndpoints, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.02, CompletionPer1K: 0.02}},
+		ModelGPT3TextDavinci003: {ContextLength: 4097, MaxOutputTokens: 4097, Endpoints: completionEndpoints, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024,
+			Pricing: Pricing{PromptPer1K: 0.02, CompletionPer1K: 0.02}},
+		// GPT base
+		ModelBabbage002: {ContextLength: 16384, MaxOutputTokens: 16384, Endpoints: []Endpoint{EndpointCompletions, EndpointFineTuning}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.0004, CompletionPer1K: 0.0004}},
+		ModelDavinci002: {ContextLength: 16384, MaxOutputTokens: 16384, Endpoints: []Endpoint{EndpointCompletions, EndpointFineTuning}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.002, CompletionPer1K: 0.002}},
+		// GPT-3.5
+		ModelGPT3Dot5Turbo0301: {ContextLength: 4096, MaxOutputTokens: 4096, Endpoints: chatEndpoints, Encoding: tokenizer.CL100kBase, DeprecationDate: shutdown2024June,
+			Pricing: Pricing{PromptPer1K: 0.0015, CompletionPer1K: 0.002}},
+		ModelGPT3Dot5Turbo: {ContextLength: 16385, MaxOutputTokens: 4096, Endpoints: []Endpoint{EndpointChatCompletions, EndpointFineTuning}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.0005, CompletionPer1K: 0.0015}},
+		// GPT-4
+		ModelGPT4: {ContextLength: 8192, MaxOutputTokens: 8192, Endpoints: chatEndpoints, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.03, CompletionPer1K: 0.06}},
+		ModelGPT40314: {ContextLength: 8192, MaxOutputTokens: 8192, Endpoints: chatEndpoints, Encoding: tokenizer.CL100kBase, DeprecationDate: shutdown2024June,
+			Pricing: Pricing{PromptPer1K: 0.03, CompletionPer1K: 0.06}},
+		ModelGPT432K: {ContextLength: 32768, MaxOutputTokens: 32768, Endpoints: chatEndpoints, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.06, CompletionPer1K: 0.12}},
+		ModelGPT432K0314: {ContextLength: 32768, MaxOutputTokens: 32768, Endpoints: chatEndpoints, Encoding: tokenizer.CL100kBase, DeprecationDate: shutdown2024June,
+			Pricing: Pricing{PromptPer1K: 0.06, CompletionPer1K: 0.12}},
+		// Edits
+		ModelTextDavinciEdit001: {Endpoints: []Endpoint{EndpointEdits}, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024},
+		ModelCodeDavinciEdit001: {Endpoints: []Endpoint{EndpointEdits}, Encoding: tokenizer.P50kBase, DeprecationDate: shutdown2024},
+		// Embeddings
+		ModelTextEmbeddingAda002: {ContextLength: 8191, Endpoints: []Endpoint{EndpointEmbeddings}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.0001}},
+		ModelTextEmbedding3Small: {ContextLength: 8191, Endpoints: []Endpoint{EndpointEmbeddings}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.00002}},
+		ModelTextEmbedding3Large: {ContextLength: 8191, Endpoints: []Endpoint{EndpointEmbeddings}, Encoding: tokenizer.CL100kBase,
+			Pricing: Pricing{PromptPer1K: 0.00013}},
+		// Audio
+		ModelWhisper: {Endpoints: audioEndpoints, Pricing: Pricing{AudioPerMinute: 0.006}},
+		// Images
+		ModelDallE2:    {Endpoints: []Endpoint{EndpointImageGenerations, EndpointImageEdits, EndpointImageVariations}},
+		ModelDallE3:    {Endpoints: []Endpoint{EndpointImageGenerations}},
+		ModelGPTImage1: {Endpoints: []Endpoint{EndpointImageGenerations, EndpointImageEdits}},
+	}
+)
+
+// RegisterModel adds the model to the registry or replaces the existing one.
+// Use it to describe custom, fine-tuned or newly released models.
+func RegisterModel(info ModelInfo) {
+	info.Endpoints = append([]Endpoint(nil), info.Endpoints...)
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[info.ID] = info
+}
+
+// LookupModel returns information about the model.
+//
+// Fine-tuned models, e.g. ft:gpt-3.5-turbo:org::id, which are not registered, inherit information
+// of their base model. For unknown models only ID is set.
+// The returned information is a copy, changing it doesn't affect the registry.
+func LookupModel(model Model) ModelInfo {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	if info, ok := models[model]; ok {
+		info.ID = model
+		info.Endpoints = append([]Endpoint(nil), info.Endpoints...)
+		return info
+	}
+	if strings.HasPrefix(string(model), "ft:") {
+		base := strings.SplitN(strings.TrimPrefix(string(model), "ft:"), ":", 2)[0]
+		if info, ok := models[Model(base)]; ok {
+			info.ID = model
+			info.Endpoints = append([]Endpoint(nil), info.Endpoints...)
+			info.Pricing = Pricing{}
+			return info
+		}
+	}
+	return ModelInfo{ID: model}
+}
+
+// checkMaxTokens returns an error if maxTokens exceeds the context length of the model.
+// Unknown models are not checked.
+func checkMaxTokens(model Model, maxTokens int) error {
+	info := LookupModel(model)
+	if info.ContextLength != 0 && maxTokens > info.ContextLength {
+		return fmt.Errorf("%w: max_tokens is %d, %s context length is %d", ErrContextLengthExceeded, maxTokens, model, info.ContextLength)
+	}
+	return nil
+}
+
+// checkModel returns an error if the model can't be used with the endpoint.
+func checkModel(model Model, endpoint Endpoint) error {
+	info := LookupModel(model)
+	if !info.SupportsEndpoint(endpoint) {
+		return fmt.Errorf("%w: %s can't be used with %s, supported endpoints: %v", ErrIncompatibleModel, model, endpoint, info.Endpoints)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0x9ef/openai-go/tokenizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupModel(t *testing.T) {
+	info := LookupModel(ModelGPT432K)
+	assert.Equal(t, ModelGPT432K, info.ID)
+	assert.Equal(t, 32768, info.ContextLength)
+	assert.Equal(t, tokenizer.CL100kBase, info.Encoding)
+	assert.True(t, info.SupportsEndpoint(EndpointChatCompletions))
+	assert.False(t, info.SupportsEndpoint(EndpointCompletions))
+	assert.InDelta(t, 0.12, info.Cost(Usage{PromptTokens: 1000, CompletionTokens: 500}), 1e-9)
+
+	info = LookupModel(ModelGPT3TextDavinci003)
+	assert.True(t, info.Deprecated(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, info.Deprecated(time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)))
+
+	info = LookupModel("ft:gpt-3.5-turbo-0613:my-org::8abc123")
+	assert.Equal(t, Model("ft:gpt-3.5-turbo-0613:my-org::8abc123"), info.ID)
+	assert.Equal(t, 0, info.ContextLength, "fine-tuned model of unknown base is unknown")
+	info = LookupModel("ft:gpt-3.5-turbo:my-org::8abc123")
+	assert.Equal(t, 16385, info.ContextLength)
+	assert.True(t, info.SupportsEndpoint(EndpointChatCompletions))
+
+	info = LookupModel("my-custom-model")
+	assert.Equal(t, ModelInfo{ID: "my-custom-model"}, info)
+	assert.True(t, info.SupportsEndpoint(EndpointEdits), "unknown model must be supported by all endpoints")
+
+	t.Cleanup(func() {
+		modelsMu.Lock()
+		defer modelsMu.Unlock()
+		delete(models, "my-custom-model")
+	})
+	RegisterModel(ModelInfo{ID: "my-custom-model", ContextLength: 1024, Endpoints: []Endpoint{EndpointCompletions}})
+	info = LookupModel("my-custom-model")
+	assert.Equal(t, 1024, info.ContextLength)
+	assert.False(t, info.SupportsEndpoint(EndpointEdits))
+
+	// Changing the returned information doesn't change the registry
+	info = LookupModel(ModelGPT3TextDavinci003)
+	info.Endpoints[0] = EndpointEdits
+	assert.True(t, LookupModel(ModelGPT3TextAda001).SupportsEndpoint(EndpointCompletions))
+	assert.False(t, LookupModel(ModelGPT3TextDavinci003).SupportsEndpoint(EndpointEdits))
+}
+
+func TestFineTuningModels(t *testing.T) {
+	for _, model := range []Model{ModelBabbage002, ModelDavinci002, ModelGPT3Dot5Turbo} {
+		assert.NoError(t, checkModel(model, EndpointFineTuning), model)
+	}
+	for _, model := range []Model{ModelGPT3Ada, ModelGPT3Babbage, ModelGPT3Curie, ModelGPT3Davince, ModelGPT4} {
+		assert.ErrorIs(t, checkModel(model, EndpointFineTuning), ErrIncompatibleModel, model)
+	}
+	assert.Equal(t, tokenizer.CL100kBase, LookupModel(ModelDavinci002).Encoding)
+}
+
+func TestIncompatibleModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request must not be sent: %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx := context.Background()
+	_, err := e.Completion(ctx, &CompletionOptions{Model: ModelGPT4, Prompt: PromptText("Hello")})
+	assert.ErrorIs(t, err, ErrIncompatibleModel)
+	_, err = e.ChatCompletion(ctx, &ChatCompletionOptions{
+		Model:    ModelWhisper,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+	})
+	assert.ErrorIs(t, err, ErrIncompatibleModel)
+	_, err = e.Edit(ctx, &EditOptions{Model: ModelGPT3TextDavinci003, Input: "Hello", Instruction: "Fix"})
+	assert.ErrorIs(t, err, ErrIncompatibleModel)
+	_, err = e.Embeddings(ctx, &EmbeddingOptions{Model: ModelGPT4, Input: EmbeddingText("Hello")})
+	assert.ErrorIs(t, err, ErrIncompatibleModel)
+}
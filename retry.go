@@ -0,0 +1,246 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how the engine retries failed requests.
+// The zero value disables retries.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	// Delay before the first retry. Each next retry doubles the delay.
+	BaseDelay time.Duration
+	// Maximum delay between attempts, including delays requested by the server of rate limited
+	// requests via Retry-After and x-ratelimit-reset-* headers. Zero means no limit.
+	MaxDelay time.Duration
+	// Fraction of the delay, between 0 and 1, which is randomized to spread retries of concurrent callers.
+	Jitter float64
+	// HTTP status codes of responses which should be retried.
+	RetryableStatusCodes []int
+	// Codes of API errors which are not retried even if the status code is retryable,
+	// e.g. insufficient_quota, which doesn't clear by waiting.
+	NonRetryableErrorCodes []string
+	// RetryableError reports whether the network error returned by HTTP client should be retried.
+	// If nil, all network errors are retried. Context cancellation is never retried.
+	RetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy retries rate limited requests, server errors and network errors up to 3 times.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.25,
+	RetryableStatusCodes: []int{
+		http.StatusRequestTimeout,
+		http.StatusConflict,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	NonRetryableErrorCodes: []string{"insufficient_quota"},
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests.
+// By default requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(e *Engine) {
+		e.retryPolicy = policy
+	}
+}
+
+// RetryError is returned when the request failed after more than one attempt.
+type RetryError struct {
+	// Number of attempts made.
+	Attempts int
+	// Error of the last attempt.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %s", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func withAttempts(attempts int, err error) error {
+	if attempts > 1 {
+		return &RetryError{Attempts: attempts, Err: err}
+	}
+	return err
+}
+
+func (p *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		if p.RetryableError != nil {
+			return p.RetryableError(err)
+		}
+		return true
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if resp.StatusCode == code {
+			return len(p.NonRetryableErrorCodes) == 0 || !contains(p.NonRetryableErrorCodes, peekErrorCode(resp))
+		}
+	}
+	return false
+}
+
+// peekErrorCode returns the code of the API error in the response body.
+// The read part of the body is put back, so the response can be read again.
+func peekErrorCode(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	var apiErr APIError
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return ""
+	}
+	return apiErr.Code
+}
+
+// delay returns duration to wait before the next attempt.
+// Delay requested by the server of the rate limited request takes precedence over exponential backoff.
+// Other responses carry the rate limit headers too, but they don't tell when the server recovers.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	var d time.Duration
+	if server, ok := serverRetryDelay(resp); ok {
+		// Jitter is added to the requested delay, so the retry is never early
+		d = server + p.jitter(server)
+	} else {
+		d = time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(attempt-1)))
+		d -= p.jitter(d)
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// jitter returns the random part of the delay.
+func (p *RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(p.Jitter * rand.Float64() * float64(d))
+}
+
+// serverRetryDelay parses delay requested by the server for the rate limited response using retry-after-ms,
+// Retry-After or x-ratelimit-reset-requests and x-ratelimit-reset-tokens headers.
+func serverRetryDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	h := resp.Header
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if s, err := strconv.ParseFloat(v, 64); err == nil && s >= 0 {
+			return time.Duration(s * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+	var d time.Duration
+	var ok bool
+	for _, k := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if reset, err := time.ParseDuration(h.Get(k)); err == nil && reset >= d {
+			d, ok = reset, true
+		}
+	}
+	return d, ok
+}
+
+// canRewind reports whether the request body can be sent again.
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// closeBody closes the request body, so the writer of the streamed body, e.g. in UploadFile, stops.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// send sends the request, retrying it according to the retry policy.
+// It returns the last response or error and the number of attempts made.
+func (e *Engine) send(req *http.Request, info requestInfo) (*http.Response, int, error) {
+	policy := e.retryPolicy
+	ctx := req.Context()
+	do := e.handler()
+	tokens := info.tokens
+	for attempt := 1; ; attempt++ {
+		if e.rateLimiter != nil {
+			if err := e.rateLimiter.Wait(ctx, info.model, tokens); err != nil {
+				closeBody(req)
+				return nil, attempt, err
+			}
+			// Each attempt is a request, but tokens are reserved once per logical request,
+			// so retries don't charge the estimate again
+			tokens = 0
+		}
+		resp, err := do(req)
+		if e.rateLimiter != nil && resp != nil {
+			e.rateLimiter.update(info.model, resp.Header)
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, attempt, ctx.Err()
+		}
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(resp, err) || !canRewind(req) {
+			return resp, attempt, err
+		}
+		delay := policy.delay(attempt, resp)
+		if e.logger != nil {
+			e.logRetry(req, info, resp, err, attempt, delay)
+		}
+		if resp != nil {
+			// Drain body to reuse connection
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempt, ctx.Err()
+		case <-timer.C:
+		}
+
+		next := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, attempt, err
+			}
+			next.Body = body
+		}
+		req = next
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	BaseDelay:            time.Millisecond,
+	MaxDelay:             10 * time.Millisecond,
+	RetryableStatusCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Multipart body must be the same on each attempt
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		f, _, err := r.FormFile("file")
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(f)
+		assert.Equal(t, "RIFF", string(b))
+		if attempts < 3 {
+			w.Header().Set("Retry-After-Ms", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"message":"The server is overloaded","type":"server_error"}}`))
+			return
+		}
+		w.Write([]byte(`{"text":"Hello"}`))
+	}))
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	r, err := e.Transcribe(context.Background(), &TranscribeOptions{
+		AudioOptions: &AudioOptions{
+			File:        bytes.NewBufferString("RIFF"),
+			AudioFormat: "wav",
+			Model:       ModelWhisper,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", r.Text)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	testCases := []struct {
+		name         string
+		statusCode   int
+		wantAttempts int
+	}{
+		{
+			name:         "error:retryable status code",
+			statusCode:   http.StatusTooManyRequests,
+			wantAttempts: 3,
+		},
+		{
+			name:         "error:not retryable status code",
+			statusCode:   http.StatusBadRequest,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(`{"error":{"message":"Failed","type":"requests"}}`))
+			}))
+			defer srv.Close()
+
+			e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+			_, err := e.ListModels(context.Background())
+			assert.Equal(t, tc.wantAttempts, attempts)
+
+			var apiErr *APIError
+			assert.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tc.statusCode, apiErr.StatusCode)
+			var retryErr *RetryError
+			if tc.wantAttempts > 1 {
+				assert.True(t, errors.As(err, &retryErr))
+				assert.Equal(t, tc.wantAttempts, retryErr.Attempts)
+			} else {
+				assert.False(t, errors.As(err, &retryErr))
+			}
+		})
+	}
+}
+
+func TestRetryContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	policy := testRetryPolicy
+	policy.MaxDelay = 0
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(policy))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := e.ListModels(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerRetryDelay(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		header     http.Header
+		want       time.Duration
+		wantOk     bool
+	}{
+		{
+			name:       "retry-after seconds",
+			statusCode: http.StatusTooManyRequests,
+			header:     http.Header{"Retry-After": []string{"2"}},
+			want:       2 * time.Second,
+			wantOk:     true,
+		},
+		{
+			name:       "retry-after-ms",
+			statusCode: http.StatusTooManyRequests,
+			header:     http.Header{"Retry-After-Ms": []string{"150"}, "Retry-After": []string{"2"}},
+			want:       150 * time.Millisecond,
+			wantOk:     true,
+		},
+		{
+			name:       "rate limit reset",
+			statusCode: http.StatusTooManyRequests,
+			header: http.Header{
+				"X-Ratelimit-Reset-Requests": []string{"1s"},
+				"X-Ratelimit-Reset-Tokens":   []string{"6m0s"},
+			},
+			want:   6 * time.Minute,
+			wantOk: true,
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusInternalServerError,
+			header: http.Header{
+				"Retry-After":                []string{"2"},
+				"X-Ratelimit-Reset-Requests": []string{"1s"},
+			},
+		},
+		{
+			name:       "no headers",
+			statusCode: http.StatusTooManyRequests,
+			header:     http.Header{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, ok := serverRetryDelay(&http.Response{StatusCode: tc.statusCode, Header: tc.header})
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, d)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, Jitter: 0.25}
+	header := http.Header{"X-Ratelimit-Reset-Tokens": []string{"20s"}}
+
+	// Rate limit headers of server errors are ignored
+	d := policy.delay(2, &http.Response{StatusCode: http.StatusInternalServerError, Header: header})
+	assert.LessOrEqual(t, d, time.Second)
+	assert.GreaterOrEqual(t, d, 750*time.Millisecond)
+
+	d = policy.delay(1, &http.Response{StatusCode: http.StatusTooManyRequests, Header: header})
+	assert.GreaterOrEqual(t, d, 20*time.Second)
+	assert.LessOrEqual(t, d, 25*time.Second)
+
+	d = policy.delay(3, nil)
+	assert.LessOrEqual(t, d, 2*time.Second)
+	assert.GreaterOrEqual(t, d, 1500*time.Millisecond)
+}
+
+func TestRetryInsufficientQuota(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`))
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy
+	policy.BaseDelay = time.Millisecond
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(policy))
+	_, err := e.ListModels(context.Background())
+	assert.Equal(t, 1, attempts)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "You exceeded your current quota", apiErr.Message)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are upper bounds of latency histogram buckets.
+var LatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// Stats is a snapshot of statistics of requests made by the engine.
+type Stats struct {
+	// Total number of requests, not including retries.
+	Requests int64
+	// Number of requests by endpoint, e.g. /chat/completions or /files/{id}.
+	Endpoints map[string]int64
+	// Number of failed requests by HTTP status code of the last attempt.
+	Failures map[int]int64
+	// Number of requests failed without response, e.g. due to network errors or cancellation.
+	Errors int64
+	// Number of retried attempts.
+	Retries int64
+	// Cumulative token usage reported by the API.
+	PromptTokens     int64
+	CompletionTokens int64
+	// Latency of requests by endpoint, including retries.
+	// For streams, it's the time until the response headers are received.
+	Latency map[string]*LatencyHistogram
+}
+
+// LatencyHistogram counts latencies by LatencyBuckets.
+type LatencyHistogram struct {
+	// Counts[i] is the number of latencies not greater than LatencyBuckets[i],
+	// the last element counts latencies greater than all buckets.
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{Counts: make([]int64, len(LatencyBuckets)+1)}
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(LatencyBuckets), func(i int) bool {
+		return d <= LatencyBuckets[i]
+	})
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Mean returns the average latency.
+func (h *LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket containing the q-quantile, 0 < q <= 1.
+// If the quantile is greater than all buckets, the largest bucket is returned.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var n int64
+	for i, c := range h.Counts[:len(LatencyBuckets)] {
+		n += c
+		if n >= rank {
+			return LatencyBuckets[i]
+		}
+	}
+	return LatencyBuckets[len(LatencyBuckets)-1]
+}
+
+func (h *LatencyHistogram) clone() *LatencyHistogram {
+	c := *h
+	c.Counts = append([]int64(nil), h.Counts...)
+	return &c
+}
+
+// statsCollector collects statistics of requests, it's safe for concurrent use.
+type statsCollector struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (c *statsCollector) recordRequest(endpoint string, resp *http.Response, err error, attempts int, latency time.Duration) {
+	endpoint = endpointName(endpoint)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &c.stats
+	if s.Endpoints == nil {
+		s.Endpoints = make(map[string]int64)
+		s.Failures = make(map[int]int64)
+		s.Latency = make(map[string]*LatencyHistogram)
+	}
+	s.Requests++
+	s.Endpoints[endpoint]++
+	if attempts > 1 {
+		s.Retries += int64(attempts - 1)
+	}
+	switch {
+	case resp == nil:
+		s.Errors++
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		s.Failures[resp.StatusCode]++
+	}
+	h, ok := s.Latency[endpoint]
+	if !ok {
+		h = newLatencyHistogram()
+		s.Latency[endpoint] = h
+	}
+	h.observe(latency)
+}
+
+func (c *statsCollector) recordUsage(u Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.PromptTokens += int64(u.PromptTokens)
+	c.stats.CompletionTokens += int64(u.CompletionTokens)
+}
+
+func (c *statsCollector) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Endpoints = make(map[string]int64, len(c.stats.Endpoints))
+	for k, v := range c.stats.Endpoints {
+		s.Endpoints[k] = v
+	}
+	s.Failures = make(map[int]int64, len(c.stats.Failures))
+	for k, v := range c.stats.Failures {
+		s.Failures[k] = v
+	}
+	s.Latency = make(map[string]*LatencyHistogram, len(c.stats.Latency))
+	for k, v := range c.stats.Latency {
+		s.Latency[k] = v.clone()
+	}
+	return s
+}
+
+// Stats returns the snapshot of statistics of requests made by the engine.
+func (e *Engine) Stats() Stats {
+	return e.stats.snapshot()
+}
+
+// endpointName replaces IDs in the endpoint path with {id},
+// so requests of the same endpoint are counted together.
+func endpointName(endpoint string) string {
+	segments := strings.Split(strings.TrimPrefix(endpoint, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "files", "models", "jobs":
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	var mu sync.Mutex
+	var fileRequests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`))
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fileRequests++
+		n := fileRequests
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"message":"No such file","type":"invalid_request_error"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL), WithRetryPolicy(testRetryPolicy))
+	ctx := context.Background()
+	opts := &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello"}},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e.SetApiKey("key-" + strconv.Itoa(i))
+			e.SetOrganizationId("org-" + strconv.Itoa(i))
+			_, err := e.ChatCompletion(ctx, opts)
+			assert.NoError(t, err)
+			e.Stats()
+		}(i)
+	}
+	wg.Wait()
+
+	_, err := e.RetrieveFile(ctx, "file-1")
+	assert.Error(t, err)
+
+	stats := e.Stats()
+	assert.Equal(t, int64(21), stats.Requests)
+	assert.Equal(t, map[string]int64{"/chat/completions": 20, "/files/{id}": 1}, stats.Endpoints)
+	assert.Equal(t, map[int]int64{http.StatusNotFound: 1}, stats.Failures)
+	assert.Equal(t, int64(1), stats.Retries)
+	assert.Equal(t, int64(0), stats.Errors)
+	assert.Equal(t, int64(200), stats.PromptTokens)
+	assert.Equal(t, int64(40), stats.CompletionTokens)
+	assert.Equal(t, int64(20), stats.Latency["/chat/completions"].Count)
+
+	// Snapshot is not modified by next requests
+	_, err = e.ChatCompletion(ctx, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), stats.Endpoints["/chat/completions"])
+	assert.Equal(t, int64(20), stats.Latency["/chat/completions"].Count)
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	assert.Equal(t, time.Duration(0), h.Quantile(0.5))
+	for _, d := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 300 * time.Millisecond, 2 * time.Minute} {
+		h.observe(d)
+	}
+	assert.Equal(t, []int64{2, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1}, h.Counts)
+	assert.Equal(t, 50*time.Millisecond, h.Quantile(0.5))
+	assert.Equal(t, 500*time.Millisecond, h.Quantile(0.75))
+	assert.Equal(t, time.Minute, h.Quantile(1))
+	assert.Equal(t, (2*time.Minute+330*time.Millisecond)/4, h.Mean())
+}
+
+func TestEndpointName(t *testing.T) {
+	assert.Equal(t, "/chat/completions", endpointName("/chat/completions"))
+	assert.Equal(t, "/files/{id}/content", endpointName("/files/file-abc/content"))
+	assert.Equal(t, "/models/{id}", endpointName("/models/gpt-4"))
+	assert.Equal(t, "/fine_tuning/jobs/{id}/events", endpointName("/fine_tuning/jobs/ftjob-1/events"))
+	assert.Equal(t, "/fine_tuning/jobs", endpointName("/fine_tuning/jobs"))
+}
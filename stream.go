@@ -0,0 +1,278 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+var (
+	sseDataPrefix = []byte("data:")
+	sseDone       = []byte("[DONE]")
+)
+
+// streamReader reads Server-Sent Events from the response body and
+// returns payloads of data events one by one.
+type streamReader struct {
+	ctx    context.Context
+	resp   *http.Response
+	reader *bufio.Reader
+	done   bool
+}
+
+func newStreamReader(ctx context.Context, resp *http.Response) *streamReader {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &streamReader{
+		ctx:    ctx,
+		resp:   resp,
+		reader: bufio.NewReader(resp.Body),
+	}
+}
+
+// next returns payload of the next data event. It returns io.EOF when [DONE] sentinel is received,
+// io.ErrUnexpectedEOF if connection was closed before the sentinel and *APIError if the server
+// sent an error in the middle of the stream.
+func (s *streamReader) next() ([]byte, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	var data []byte
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			if ctxErr := s.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		eof := err == io.EOF
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			// Blank line dispatches the event
+			if len(data) != 0 {
+				return s.dispatch(data)
+			}
+		case bytes.HasPrefix(line, sseDataPrefix):
+			if len(data) != 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(line[len(sseDataPrefix):], []byte(" "))...)
+		default:
+			// Ignore comments and other fields (event, id, retry)
+		}
+		if eof {
+			if len(data) != 0 {
+				return s.dispatch(data)
+			}
+			s.done = true
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+}
+
+func (s *streamReader) dispatch(data []byte) ([]byte, error) {
+	if bytes.Equal(data, sseDone) {
+		s.done = true
+		return nil, io.EOF
+	}
+	var probe struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && len(probe.Error) != 0 && !bytes.Equal(probe.Error, []byte("null")) {
+		s.done = true
+		apiErr := &APIError{
+			StatusCode: s.resp.StatusCode,
+			RequestID:  requestID(s.resp.Header),
+			Header:     s.resp.Header,
+		}
+		if err := json.Unmarshal(data, apiErr); err != nil {
+			return nil, err
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+func (s *streamReader) close() error {
+	s.done = true
+	return s.resp.Body.Close()
+}
+
+// CompletionStream is a stream of completion chunks.
+// The caller must call Close when finished reading the stream.
+type CompletionStream struct {
+	reader *streamReader
+	stats  *statsCollector
+}
+
+// Recv returns the next completion chunk from the stream.
+// It returns io.EOF when the stream is finished.
+func (s *CompletionStream) Recv() (*CompletionResponse, error) {
+	b, err := s.reader.next()
+	if err != nil {
+		return nil, err
+	}
+	var chunk CompletionResponse
+	if err := json.Unmarshal(b, &chunk); err != nil {
+		return nil, err
+	}
+	s.stats.recordUsage(chunk.Usage)
+	return &chunk, nil
+}
+
+// Close closes the underlying response body.
+func (s *CompletionStream) Close() error {
+	return s.reader.close()
+}
+
+// StreamCompletion is the same as Completion, but partial completions are sent
+// back as they are generated using Server-Sent Events.
+//
+// Docs: https://platform.openai.com/docs/api-reference/completions/create#completions-create-stream
+func (e *Engine) StreamCompletion(ctx context.Context, opts *CompletionOptions) (*CompletionStream, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	if opts.BestOf > 1 {
+		return nil, errors.New("best_of can't be used with stream")
+	}
+	if err := checkModel(opts.Model, EndpointCompletions); err != nil {
+		return nil, err
+	}
+	if err := checkMaxTokens(opts.Model, opts.MaxTokens); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/completions", opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	r, err := marshalJson(struct {
+		*CompletionOptions
+		Stream bool `json:"stream"`
+	}{opts.withDefaults(), true})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doStreamReq(ctx, uri, r)
+	if err != nil {
+		return nil, err
+	}
+	return &CompletionStream{reader: newStreamReader(ctx, resp), stats: &e.stats}, nil
+}
+
+type ChatCompletionStreamResponse struct {
+	Id                string                       `json:"id"`
+	Object            string                       `json:"object"`
+	Created           int                          `json:"created"`
+	Model             Model                        `json:"model"`
+	SystemFingerprint string                       `json:"system_fingerprint"`
+	Choices           []ChatCompletionStreamChoice `json:"choices"`
+	// Usage is present only in the last chunk, if it was requested.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type ChatCompletionStreamChoice struct {
+	Index        int              `json:"index"`
+	Delta        ChatMessageDelta `json:"delta"`
+	FinishReason FinishReason     `json:"finish_reason"`
+}
+
+// ChatMessageDelta is a part of the chat message generated by streamed model response.
+type ChatMessageDelta struct {
+	Role      Role            `json:"role"`
+	Content   string          `json:"content"`
+	ToolCalls []ToolCallDelta `json:"tool_calls"`
+}
+
+// ToolCallDelta is a part of the tool call generated by streamed model response.
+// Parts of the same tool call share the same index.
+type ToolCallDelta struct {
+	Index    int    `json:"index"`
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatCompletionStream is a stream of chat completion chunks.
+// The caller must call Close when finished reading the stream.
+type ChatCompletionStream struct {
+	reader *streamReader
+	stats  *statsCollector
+}
+
+// Recv returns the next chat completion chunk from the stream.
+// It returns io.EOF when the stream is finished.
+func (s *ChatCompletionStream) Recv() (*ChatCompletionStreamResponse, error) {
+	b, err := s.reader.next()
+	if err != nil {
+		return nil, err
+	}
+	var chunk ChatCompletionStreamResponse
+	if err := json.Unmarshal(b, &chunk); err != nil {
+		return nil, err
+	}
+	if chunk.Usage != nil {
+		s.stats.recordUsage(*chunk.Usage)
+	}
+	return &chunk, nil
+}
+
+// Close closes the underlying response body.
+func (s *ChatCompletionStream) Close() error {
+	return s.reader.close()
+}
+
+// StreamChatCompletion is the same as ChatCompletion, but message deltas are sent
+// back as they are generated using Server-Sent Events.
+//
+// Docs: https://platform.openai.com/docs/api-reference/chat/create#chat-create-stream
+func (e *Engine) StreamChatCompletion(ctx context.Context, opts *ChatCompletionOptions) (*ChatCompletionStream, error) {
+	if err := e.validate.StructCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+	if err := checkModel(opts.Model, EndpointChatCompletions); err != nil {
+		return nil, err
+	}
+	uri, err := e.deploymentURL("/chat/completions", opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	r, err := marshalJson(struct {
+		*ChatCompletionOptions
+		Stream bool `json:"stream"`
+	}{opts, true})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.doStreamReq(ctx, uri, r)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatCompletionStream{reader: newStreamReader(ctx, resp), stats: &e.stats}, nil
+}
+
+func (e *Engine) doStreamReq(ctx context.Context, uri string, body io.Reader) (*http.Response, error) {
+	req, err := e.newReq(ctx, http.MethodPost, uri, "json", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	return e.doReq(req)
+}
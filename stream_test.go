@@ -0,0 +1,168 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStreamServer(t *testing.T, frames ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Azure sets only apim-request-id
+		w.Header().Set("Apim-Request-Id", "apim-123")
+		for _, frame := range frames {
+			io.WriteString(w, frame)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestStreamChatCompletion(t *testing.T) {
+	srv := newStreamServer(t,
+		": keep-alive\n\n",
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`+"\n\n",
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hello"}}]}`+"\n\n",
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":" world"}}]}`+"\r\n\r\n",
+		`data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\n",
+		"data: [DONE]\n\n",
+	)
+	defer srv.Close()
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	s, err := e.StreamChatCompletion(context.Background(), &ChatCompletionOptions{
+		Model:    ModelGPT3Dot5Turbo,
+		Messages: []ChatMessage{{Role: RoleUser, Content: "Hello!"}},
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	var content strings.Builder
+	var finishReason FinishReason
+	for {
+		chunk, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		finishReason = chunk.Choices[0].FinishReason
+	}
+	assert.Equal(t, "Hello world", content.String())
+	assert.Equal(t, FinishReasonStop, finishReason)
+
+	_, err = s.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStreamCompletion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		frames   []string
+		wantText string
+		wantErr  func(t *testing.T, err error)
+	}{
+		{
+			name: "success:done",
+			frames: []string{
+				`data: {"id":"cmpl-1","choices":[{"text":"Hello","index":0}]}` + "\n\n",
+				`data: {"id":"cmpl-1","choices":[{"text":" world","index":0,"finish_reason":"stop"}]}` + "\n\n",
+				"data: [DONE]\n\n",
+			},
+			wantText: "Hello world",
+			wantErr: func(t *testing.T, err error) {
+				assert.Equal(t, io.EOF, err)
+			},
+		},
+		{
+			name: "error:api error in the middle of the stream",
+			frames: []string{
+				`data: {"id":"cmpl-1","choices":[{"text":"Hello","index":0}]}` + "\n\n",
+				`data: {"error":{"message":"The server had an error","type":"server_error"}}` + "\n\n",
+			},
+			wantText: "Hello",
+			wantErr: func(t *testing.T, err error) {
+				var apiErr *APIError
+				assert.True(t, errors.As(err, &apiErr))
+				assert.Equal(t, "server_error", apiErr.Type)
+				assert.Equal(t, "apim-123", apiErr.RequestID)
+			},
+		},
+		{
+			name: "error:connection closed before done",
+			frames: []string{
+				`data: {"id":"cmpl-1","choices":[{"text":"Hello","index":0}]}` + "\n\n",
+			},
+			wantText: "Hello",
+			wantErr: func(t *testing.T, err error) {
+				assert.Equal(t, io.ErrUnexpectedEOF, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newStreamServer(t, tc.frames...)
+			defer srv.Close()
+
+			e := NewWithOptions("test", WithBaseURL(srv.URL))
+			s, err := e.StreamCompletion(context.Background(), &CompletionOptions{
+				Model:  DefaultModel,
+				Prompt: PromptText("Say hello"),
+			})
+			assert.NoError(t, err)
+			defer s.Close()
+
+			var text strings.Builder
+			for {
+				chunk, err := s.Recv()
+				if err != nil {
+					tc.wantErr(t, err)
+					break
+				}
+				text.WriteString(chunk.Choices[0].Text)
+			}
+			assert.Equal(t, tc.wantText, text.String())
+		})
+	}
+}
+
+func TestStreamContextCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `data: {"id":"cmpl-1","choices":[{"text":"Hello","index":0}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-unblock
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	e := NewWithOptions("test", WithBaseURL(srv.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := e.StreamCompletion(ctx, &CompletionOptions{
+		Model:  DefaultModel,
+		Prompt: PromptText("Say hello"),
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.Recv()
+	assert.NoError(t, err)
+	cancel()
+	_, err = s.Recv()
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package tokenizer
+
+import "math"
+
+const noRank = math.MaxInt32
+
+// bytePairEncode encodes the piece by repeatedly merging the adjacent pair of parts
+// with the lowest rank until no pair is in the ranks.
+func bytePairEncode(piece []byte, ranks map[string]int) []int {
+	if rank, ok := ranks[string(piece)]; ok {
+		return []int{rank}
+	}
+	if len(piece) == 1 {
+		// All single bytes are in ranks of real encodings
+		return nil
+	}
+
+	type part struct {
+		start int
+		rank  int // rank of the pair starting at this part
+	}
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: noRank}
+	}
+	// pairRank returns rank of bytes covered by parts i and i+1.
+	pairRank := func(i int) int {
+		if i+2 < len(parts) {
+			if rank, ok := ranks[string(piece[parts[i].start:parts[i+2].start])]; ok {
+				return rank
+			}
+		}
+		return noRank
+	}
+	for i := 0; i < len(parts)-2; i++ {
+		parts[i].rank = pairRank(i)
+	}
+
+	for {
+		minIdx, minRank := -1, noRank
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < minRank {
+				minIdx, minRank = i, parts[i].rank
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+		// Merge parts minIdx and minIdx+1 and update ranks of pairs around
+		parts = append(parts[:minIdx+1], parts[minIdx+2:]...)
+		parts[minIdx].rank = pairRank(minIdx)
+		if minIdx > 0 {
+			parts[minIdx-1].rank = pairRank(minIdx - 1)
+		}
+	}
+
+	tokens := make([]int, 0, len(parts)-1)
+	for i := 0; i < len(parts)-1; i++ {
+		if rank, ok := ranks[string(piece[parts[i].start:parts[i+1].start])]; ok {
+			tokens = append(tokens, rank)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// gen downloads ranks of the encodings, verifies their checksums and writes them gzipped into the data directory.
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const baseURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+var files = []struct {
+	name   string
+	sha256 string
+}{
+	{"cl100k_base.tiktoken", "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7"},
+	{"p50k_base.tiktoken", "94b5ca7dff4d00767bc256fdd1b27e5b17361d7b8a5f968547f9f23eb70d2069"},
+	{"r50k_base.tiktoken", "306cd27f03c1a714eca7108e03d66b7dc042abe8c258b44c199a7ed9838dd930"},
+}
+
+func main() {
+	for _, f := range files {
+		if err := download(f.name, f.sha256); err != nil {
+			log.Fatalf("%s: %s", f.name, err)
+		}
+	}
+}
+
+func download(name, checksum string) error {
+	resp, err := http.Get(baseURL + name)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	if got := hex.EncodeToString(sum[:]); got != checksum {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, checksum)
+	}
+	f, err := os.Create(filepath.Join("data", name+".gz"))
+	if err != nil {
+		return err
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := zw.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitFunc splits text into pieces which are encoded by BPE independently.
+// Regular expressions used by tiktoken rely on lookahead which is not supported
+// by regexp package, so they are implemented by hand.
+type splitFunc func(text string) []string
+
+func isLetter(r rune) bool { return unicode.IsLetter(r) }
+func isNumber(r rune) bool { return unicode.IsNumber(r) }
+func isSpace(r rune) bool  { return unicode.IsSpace(r) }
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+// isOther reports whether r matches [^\s\p{L}\p{N}].
+func isOther(r rune) bool {
+	return !isSpace(r) && !isLetter(r) && !isNumber(r)
+}
+
+// runeAt returns rune at byte offset i and its size, or (utf8.RuneError, 0) at the end of text.
+func runeAt(text string, i int) (rune, int) {
+	if i >= len(text) {
+		return utf8.RuneError, 0
+	}
+	return utf8.DecodeRuneInString(text[i:])
+}
+
+// skip returns offset of the first rune at or after i which doesn't satisfy f.
+// No more than limit runes are skipped if limit is positive.
+func skip(text string, i int, f func(rune) bool, limit int) int {
+	for n := 0; i < len(text) && (limit <= 0 || n < limit); n++ {
+		r, size := runeAt(text, i)
+		if !f(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// contraction matches 's|'t|'re|'ve|'m|'ll|'d at offset i and returns its end or -1.
+func contraction(text string, i int, ignoreCase bool) int {
+	if text[i] != '\'' {
+		return -1
+	}
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		end := i + 1 + len(suffix)
+		if end > len(text) {
+			continue
+		}
+		s := text[i+1 : end]
+		if s == suffix || (ignoreCase && equalFoldASCII(s, suffix)) {
+			return end
+		}
+	}
+	return -1
+}
+
+func equalFoldASCII(s, t string) bool {
+	if len(s) != len(t) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		a, b := s[i], t[i]
+		if 'A' <= a && a <= 'Z' {
+			a += 'a' - 'A'
+		}
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// whitespace matches \s+(?!\S)|\s+ at offset i and returns its end.
+func whitespace(text string, i int) int {
+	end := skip(text, i, isSpace, 0)
+	if end == len(text) {
+		return end
+	}
+	// Leave the last whitespace to be a prefix of the next piece
+	_, size := utf8.DecodeLastRuneInString(text[i:end])
+	if end-size > i {
+		return end - size
+	}
+	return end
+}
+
+// splitR50k splits text by the pattern used by r50k_base and p50k_base encodings:
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+func splitR50k(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		end := contraction(text, i, false)
+		if end < 0 {
+			r, size := runeAt(text, i)
+			start := i
+			if r == ' ' {
+				// Optional leading space
+				r, size = runeAt(text, i+1)
+				start = i + 1
+			}
+			switch {
+			case size > 0 && isLetter(r):
+				end = skip(text, start, isLetter, 0)
+			case size > 0 && isNumber(r):
+				end = skip(text, start, isNumber, 0)
+			case size > 0 && isOther(r):
+				end = skip(text, start, isOther, 0)
+			default:
+				end = whitespace(text, i)
+			}
+		}
+		pieces = append(pieces, text[i:end])
+		i = end
+	}
+	return pieces
+}
+
+// splitCL100k splits text by the pattern used by cl100k_base encoding:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCL100k(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		end := contraction(text, i, true)
+		if end < 0 {
+			end = matchCL100k(text, i)
+		}
+		pieces = append(pieces, text[i:end])
+		i = end
+	}
+	return pieces
+}
+
+func matchCL100k(text string, i int) int {
+	r, size := runeAt(text, i)
+	next, nextSize := runeAt(text, i+size)
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	if isLetter(r) {
+		return skip(text, i, isLetter, 0)
+	}
+	if !isNewline(r) && !isNumber(r) && nextSize > 0 && isLetter(next) {
+		return skip(text, i+size, isLetter, 0)
+	}
+	// \p{N}{1,3}
+	if isNumber(r) {
+		return skip(text, i, isNumber, 3)
+	}
+	// ` ?[^\s\p{L}\p{N}]+[\r\n]*`
+	start := -1
+	switch {
+	case isOther(r):
+		start = i
+	case r == ' ' && nextSize > 0 && isOther(next):
+		start = i + size
+	}
+	if start >= 0 {
+		end := skip(text, start, isOther, 0)
+		return skip(text, end, isNewline, 0)
+	}
+	// \s*[\r\n]+
+	end := skip(text, i, isSpace, 0)
+	for j := end; j > i; {
+		r, size := utf8.DecodeLastRuneInString(text[i:j])
+		if isNewline(r) {
+			return j
+		}
+		j -= size
+	}
+	// \s+(?!\S)|\s+
+	return whitespace(text, i)
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package tokenizer implements byte pair encoding used by OpenAI models,
+// so the number of tokens in a prompt can be counted offline before sending it.
+//
+// Ranks of encodings are embedded into the package from the gzipped tiktoken files
+// in the data directory. Run go generate to download them again.
+package tokenizer
+
+//go:generate go run gen.go
+
+import (
+	"bufio"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of supported encodings.
+const (
+	CL100kBase = "cl100k_base"
+	P50kBase   = "p50k_base"
+	R50kBase   = "r50k_base"
+)
+
+const endOfText = "<|endoftext|>"
+
+var (
+	// ErrUnknownEncoding is returned when encoding is not supported.
+	ErrUnknownEncoding = errors.New("unknown encoding")
+	// ErrUnknownModel is returned when encoding of the model is not known.
+	ErrUnknownModel = errors.New("unknown model")
+)
+
+//go:embed data/*.tiktoken.gz
+var data embed.FS
+
+type encodingSpec struct {
+	special map[string]int
+	split   splitFunc
+}
+
+var specs = map[string]encodingSpec{
+	CL100kBase: {
+		special: map[string]int{
+			endOfText:         100257,
+			"<|fim_prefix|>":  100258,
+			"<|fim_middle|>":  100259,
+			"<|fim_suffix|>":  100260,
+			"<|endofprompt|>": 100276,
+		},
+		split: splitCL100k,
+	},
+	P50kBase: {
+		special: map[string]int{endOfText: 50256},
+		split:   splitR50k,
+	},
+	R50kBase: {
+		special: map[string]int{endOfText: 50256},
+		split:   splitR50k,
+	},
+}
+
+// Encoding converts text to tokens and back.
+// It is safe for concurrent use.
+type Encoding struct {
+	name           string
+	ranks          map[string]int
+	decoder        map[int]string
+	special        map[string]int
+	specialDecoder map[int]string
+	split          splitFunc
+}
+
+func newEncoding(name string, ranks map[string]int, special map[string]int, split splitFunc) *Encoding {
+	e := &Encoding{
+		name:           name,
+		ranks:          ranks,
+		decoder:        make(map[int]string, len(ranks)),
+		special:        special,
+		specialDecoder: make(map[int]string, len(special)),
+		split:          split,
+	}
+	for k, v := range ranks {
+		e.decoder[v] = k
+	}
+	for k, v := range special {
+		e.specialDecoder[v] = k
+	}
+	return e
+}
+
+type loadedEncoding struct {
+	once sync.Once
+	enc  *Encoding
+	err  error
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = make(map[string]*loadedEncoding)
+)
+
+// GetEncoding returns encoding by its name. Encodings are loaded once and cached.
+func GetEncoding(name string) (*Encoding, error) {
+	spec, ok := specs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+	loadedMu.Lock()
+	l, ok := loaded[name]
+	if !ok {
+		l = &loadedEncoding{}
+		loaded[name] = l
+	}
+	loadedMu.Unlock()
+
+	l.once.Do(func() {
+		ranks, err := loadRanks(name)
+		if err != nil {
+			l.err = fmt.Errorf("load %s: %w", name, err)
+			return
+		}
+		l.enc = newEncoding(name, ranks, spec.special, spec.split)
+	})
+	return l.enc, l.err
+}
+
+// loadRanks reads embedded ranks of the encoding.
+func loadRanks(name string) (map[string]int, error) {
+	f, err := data.Open("data/" + name + ".tiktoken.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return parseRanks(zr)
+}
+
+// parseRanks parses tiktoken file, where each line is base64 encoded token and its rank.
+func parseRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: decode token: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse rank: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+// Name returns the name of the encoding.
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode converts text to tokens. Special tokens are encoded as ordinary text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		tokens = append(tokens, bytePairEncode([]byte(piece), e.ranks)...)
+	}
+	return tokens
+}
+
+// EncodeWithSpecialTokens converts text to tokens, encoding special tokens
+// such as <|endoftext|> as their own ids.
+func (e *Encoding) EncodeWithSpecialTokens(text string) []int {
+	var tokens []int
+	for len(text) != 0 {
+		// Find the first special token in the rest of text
+		pos, special := len(text), ""
+		for s := range e.special {
+			if i := strings.Index(text, s); i >= 0 && (i < pos || (i == pos && len(s) > len(special))) {
+				pos, special = i, s
+			}
+		}
+		tokens = append(tokens, e.Encode(text[:pos])...)
+		if special == "" {
+			break
+		}
+		tokens = append(tokens, e.special[special])
+		text = text[pos+len(special):]
+	}
+	return tokens
+}
+
+// Decode converts tokens back to text.
+// Invalid UTF-8 sequences, e.g. when tokens end in the middle of a character, are kept as is.
+func (e *Encoding) Decode(tokens []int) (string, error) {
+	var b strings.Builder
+	for _, token := range tokens {
+		if s, ok := e.decoder[token]; ok {
+			b.WriteString(s)
+			continue
+		}
+		if s, ok := e.specialDecoder[token]; ok {
+			b.WriteString(s)
+			continue
+		}
+		return "", fmt.Errorf("unknown token %d", token)
+	}
+	return b.String(), nil
+}
+
+// Count returns the number of tokens in text.
+func (e *Encoding) Count(text string) int {
+	return len(e.Encode(text))
+}
+
+// EncodingNameForModel returns the name of the encoding used by the model.
+func EncodingNameForModel(model string) (string, error) {
+	if name, ok := modelEncodings[model]; ok {
+		return name, nil
+	}
+	for _, p := range modelPrefixEncodings {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.encoding, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownModel, model)
+}
+
+var modelEncodings = map[string]string{
+	// Chat
+	"gpt-4":         CL100kBase,
+	"gpt-3.5-turbo": CL100kBase,
+	"gpt-35-turbo":  CL100kBase, // Azure deployment name
+	// Base
+	"davinci-002": CL100kBase,
+	"babbage-002": CL100kBase,
+	// Embeddings
+	"text-embedding-ada-002": CL100kBase,
+	"text-embedding-3-small": CL100kBase,
+	"text-embedding-3-large": CL100kBase,
+	// GPT-3.5
+	"text-davinci-003": P50kBase,
+	"text-davinci-002": P50kBase,
+	// Codex
+	"code-davinci-002": P50kBase,
+	"code-davinci-001": P50kBase,
+	"code-cushman-002": P50kBase,
+	"code-cushman-001": P50kBase,
+	// Edits
+	"text-davinci-edit-001": P50kBase,
+	"code-davinci-edit-001": P50kBase,
+	// GPT-3
+	"text-davinci-001": R50kBase,
+	"text-curie-001":   R50kBase,
+	"text-babbage-001": R50kBase,
+	"text-ada-001":     R50kBase,
+	"davinci":          R50kBase,
+	"curie":            R50kBase,
+	"babbage":          R50kBase,
+	"ada":              R50kBase,
+}
+
+var modelPrefixEncodings = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4-", CL100kBase},
+	{"gpt-3.5-turbo-", CL100kBase},
+	{"gpt-35-turbo-", CL100kBase},
+	// Fine-tuned models
+	{"ft:gpt-4", CL100kBase},
+	{"ft:gpt-3.5-turbo", CL100kBase},
+	{"ft:davinci-002", CL100kBase},
+	{"ft:babbage-002", CL100kBase},
+}
+
+// ForModel returns the encoding used by the model.
+func ForModel(model string) (*Encoding, error) {
+	name, err := EncodingNameForModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return GetEncoding(name)
+}
+
+// CountTokens returns the number of tokens in the prompt for the model.
+func CountTokens(model string, text string) (int, error) {
+	enc, err := ForModel(model)
+	if err != nil {
+		return 0, err
+	}
+	return enc.Count(text), nil
+}
+
+// Message is a chat message which tokens are counted.
+type Message struct {
+	Role    string
+	Name    string
+	Content string
+}
+
+// CountMessageTokens returns the number of prompt tokens consumed by chat messages,
+// including formatting overhead added to each message and the reply.
+//
+// Learn more: https://github.com/openai/openai-cookbook/blob/main/examples/How_to_count_tokens_with_tiktoken.ipynb
+func CountMessageTokens(model string, messages []Message) (int, error) {
+	enc, err := ForModel(model)
+	if err != nil {
+		return 0, err
+	}
+	return countMessageTokens(enc, model, messages), nil
+}
+
+func countMessageTokens(enc *Encoding, model string, messages []Message) int {
+	// Every message follows <|start|>{role/name}\n{content}<|end|>\n
+	tokensPerMessage, tokensPerName := 3, 1
+	if model == "gpt-3.5-turbo-0301" {
+		tokensPerMessage, tokensPerName = 4, -1 // if there's a name, the role is omitted
+	}
+	n := 0
+	for _, m := range messages {
+		n += tokensPerMessage + enc.Count(m.Role) + enc.Count(m.Content)
+		if m.Name != "" {
+			n += enc.Count(m.Name) + tokensPerName
+		}
+	}
+	// Every reply is primed with <|start|>assistant<|message|>
+	return n + 3
+}
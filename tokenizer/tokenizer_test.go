@@ -0,0 +1,179 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEncoding returns encoding with ranks for all single bytes
+// and a few merges, like the ones of real encodings.
+func newTestEncoding() *Encoding {
+	ranks := make(map[string]int)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, merge := range []string{"ab", "bc", "abc", " a", " abc"} {
+		ranks[merge] = 256 + i
+	}
+	return newEncoding("test", ranks, map[string]int{endOfText: 1000}, splitR50k)
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		want []int
+	}{
+		{
+			name: "whole piece is a token",
+			text: "abc",
+			want: []int{258},
+		},
+		{
+			name: "merge by the lowest rank first",
+			text: "bcab",
+			want: []int{257, 256},
+		},
+		{
+			name: "pieces are encoded separately",
+			text: "abc abc",
+			want: []int{258, 260},
+		},
+		{
+			name: "special tokens are ordinary text",
+			text: "a<|endoftext|>",
+			want: []int{'a', '<', '|', 'e', 'n', 'd', 'o', 'f', 't', 'e', 'x', 't', '|', '>'},
+		},
+		{
+			name: "empty",
+			text: "",
+			want: nil,
+		},
+	}
+
+	enc := newTestEncoding()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := enc.Encode(tc.text)
+			assert.Equal(t, tc.want, tokens)
+			assert.Equal(t, len(tc.want), enc.Count(tc.text))
+			text, err := enc.Decode(tokens)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.text, text)
+		})
+	}
+}
+
+func TestEncodeWithSpecialTokens(t *testing.T) {
+	enc := newTestEncoding()
+	tokens := enc.EncodeWithSpecialTokens("abc<|endoftext|> abc")
+	assert.Equal(t, []int{258, 1000, 260}, tokens)
+	text, err := enc.Decode(tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc<|endoftext|> abc", text)
+
+	_, err = enc.Decode([]int{5000})
+	assert.Error(t, err)
+}
+
+func TestSplit(t *testing.T) {
+	text := "Hello world!  How's it   going?\n\n123456 I'M"
+	assert.Equal(t, []string{
+		"Hello", " world", "!", " ", " How", "'s", " it", "  ", " going", "?", "\n", "\n", "123456", " I", "'", "M",
+	}, splitR50k(text))
+	assert.Equal(t, []string{
+		"Hello", " world", "!", " ", " How", "'s", " it", "  ", " going", "?\n\n", "123", "456", " I", "'M",
+	}, splitCL100k(text))
+	assert.Equal(t, []string{"Привет", ",", " мир", " \t\n", "日本語"}, splitCL100k("Привет, мир \t\n日本語"))
+}
+
+func TestCountMessageTokens(t *testing.T) {
+	enc := newTestEncoding()
+	messages := []Message{
+		{Role: "ab", Content: "abc abc"},
+		{Role: "bc", Name: "abc", Content: "a"},
+	}
+	// (3 + 1 + 2) + (3 + 1 + 1 + 1 + 1) + 3
+	assert.Equal(t, 16, countMessageTokens(enc, "gpt-4", messages))
+	// (4 + 1 + 2) + (4 + 1 + 1 + 1 - 1) + 3
+	assert.Equal(t, 16, countMessageTokens(enc, "gpt-3.5-turbo-0301", messages))
+}
+
+func TestEncodingNameForModel(t *testing.T) {
+	testCases := []struct {
+		model   string
+		want    string
+		wantErr error
+	}{
+		{model: "gpt-4", want: CL100kBase},
+		{model: "gpt-4-32k-0314", want: CL100kBase},
+		{model: "gpt-3.5-turbo-0301", want: CL100kBase},
+		{model: "ft:gpt-3.5-turbo-0613:org::abc123", want: CL100kBase},
+		{model: "text-davinci-003", want: P50kBase},
+		{model: "code-cushman-001", want: P50kBase},
+		{model: "davinci", want: R50kBase},
+		{model: "whisper-1", wantErr: ErrUnknownModel},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.model, func(t *testing.T) {
+			name, err := EncodingNameForModel(tc.model)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, name)
+		})
+	}
+}
+
+func TestGetEncoding(t *testing.T) {
+	_, err := GetEncoding("unknown")
+	assert.ErrorIs(t, err, ErrUnknownEncoding)
+
+	// Tokens are produced by tiktoken
+	testCases := []struct {
+		encoding string
+		text     string
+		want     []int
+	}{
+		{encoding: CL100kBase, text: "hello world", want: []int{15339, 1917}},
+		{encoding: CL100kBase, text: "tiktoken is great!", want: []int{83, 1609, 5963, 374, 2294, 0}},
+		{encoding: CL100kBase, text: "hello world!你好，世界！", want: []int{15339, 1917, 0, 57668, 53901, 3922, 3574, 244, 98220, 6447}},
+		{encoding: CL100kBase, text: "👍", want: []int{9468, 239, 235}},
+		{encoding: CL100kBase, text: " \u00850", want: []int{220, 126, 227, 15}},
+		{encoding: CL100kBase, text: "def f(x):\n    return x  +  1\n\n", want: []int{755, 282, 2120, 997, 262, 471, 865, 220, 489, 220, 220, 16, 271}},
+		{encoding: CL100kBase, text: "I'm 12345 years old, y'all'LL see.", want: []int{40, 2846, 220, 4513, 1774, 1667, 2362, 11, 379, 65948, 6, 4178, 1518, 13}},
+		{encoding: CL100kBase, text: "00000000", want: []int{931, 931, 410}},
+		{encoding: P50kBase, text: "hello world", want: []int{31373, 995}},
+		{encoding: P50kBase, text: "def f(x):\n    return x  +  1\n\n", want: []int{4299, 277, 7, 87, 2599, 198, 50258, 1441, 2124, 220, 1343, 220, 352, 628}},
+		{encoding: R50kBase, text: "hello world", want: []int{31373, 995}},
+		{encoding: R50kBase, text: "tiktoken is great!", want: []int{83, 1134, 30001, 318, 1049, 0}},
+		{encoding: R50kBase, text: "👍", want: []int{41840, 235}},
+		{encoding: R50kBase, text: "def f(x):\n    return x  +  1\n\n", want: []int{4299, 277, 7, 87, 2599, 198, 220, 220, 220, 1441, 2124, 220, 1343, 220, 352, 628}},
+		{encoding: R50kBase, text: "I'm 12345 years old, y'all'LL see.", want: []int{40, 1101, 17031, 2231, 812, 1468, 11, 331, 6, 439, 6, 3069, 766, 13}},
+		{encoding: R50kBase, text: "00000000", want: []int{8269}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.encoding+"/"+tc.text, func(t *testing.T) {
+			enc, err := GetEncoding(tc.encoding)
+			if !assert.NoError(t, err) {
+				return
+			}
+			tokens := enc.Encode(tc.text)
+			assert.Equal(t, tc.want, tokens)
+			text, err := enc.Decode(tokens)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.text, text)
+		})
+	}
+
+	enc, err := GetEncoding(CL100kBase)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{15339, 220, 100257}, enc.EncodeWithSpecialTokens("hello <|endoftext|>"))
+	}
+	n, err := CountTokens("gpt-4", "tiktoken is great!")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+}